@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/boltdb/bolt"
 
@@ -18,6 +20,19 @@ var bucketDoc = []byte("doc")
 var bucketRevs = []byte("revs")
 var bucketDocStats = []byte("stats")
 var bucketDaily = []byte("daily")
+var bucketTargets = []byte("targets")
+var bucketSettings = []byte("settings")
+var bucketRollups = []byte("rollups")
+var bucketProjects = []byte("projects")
+var bucketTerms = []byte("terms")
+var bucketCorpus = []byte("corpus")
+var bucketBlame = []byte("blame")
+var bucketCuts = []byte("cuts")
+var bucketRevText = []byte("revtext")
+var bucketPrints = []byte("prints")
+
+const settingsKey = "user"
+const corpusKey = "all"
 
 type StatTrackerDB struct {
 	db *bolt.DB
@@ -156,6 +171,61 @@ func (st *StatTrackerDB) WriteRevision(fileId string, rev *drive.Revision) {
 	}
 }
 
+// LoadRevisions returns the stored revisions of a file, oldest first
+func (st *StatTrackerDB) LoadRevisions(fileId string) []*drive.Revision {
+	result := []*drive.Revision{}
+	prefix := fileId + " "
+
+	loadFunc := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketRevs)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = c.Next() {
+			var rev drive.Revision
+			errMarshal := json.Unmarshal(v, &rev)
+			if errMarshal != nil {
+				log.Println("Unmarshal failed:", errMarshal)
+				return errMarshal
+			}
+			result = append(result, &rev)
+		}
+		return nil
+	}
+
+	// retrieve the data
+	txErr := st.db.View(loadFunc)
+	if txErr != nil {
+		log.Println("Load revisions failed:", txErr)
+	}
+
+	// Keys sort revision ids as text, put them back in time order
+	sort.Sort(revisionsByDate(result))
+	return result
+}
+
+type revisionsByDate []*drive.Revision
+
+func (a revisionsByDate) Len() int           { return len(a) }
+func (a revisionsByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a revisionsByDate) Less(i, j int) bool { return a[i].ModifiedDate < a[j].ModifiedDate }
+
+// WriteRevisionText keeps an export of a revision so stats can be worked
+// out again without fetching it
+func (st *StatTrackerDB) WriteRevisionText(fileId string, revId string, mimeType string, text string) {
+	st.putJSON(bucketRevText, fileId+" "+revId+" "+mimeType, text)
+}
+
+// LoadRevisionText returns a stored export of a revision, false if it was
+// never stored
+func (st *StatTrackerDB) LoadRevisionText(fileId string, revId string, mimeType string) (string, bool) {
+	var result string
+	ok := st.getJSON(bucketRevText, fileId+" "+revId+" "+mimeType, &result)
+	return result, ok
+}
+
 func (st *StatTrackerDB) WriteUserStats(fStat *stat.UserStat) {
 	writeFunc := func(tx *bolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists(bucketUser)
@@ -247,6 +317,41 @@ func (st *StatTrackerDB) WriteFileStats(fStat *stat.DocStat) {
 	if txErr != nil {
 		log.Fatal(txErr)
 	}
+
+	// Fingerprints are kept apart so copies can be found without loading every stat
+	st.putJSON(bucketPrints, fStat.FileId, fStat.Print())
+}
+
+// LoadPrints returns the fingerprints of every file with stats
+func (st *StatTrackerDB) LoadPrints() []stat.DocPrint {
+	result := []stat.DocPrint{}
+
+	loadFunc := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketPrints)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var p stat.DocPrint
+			errMarshal := json.Unmarshal(v, &p)
+			if errMarshal != nil {
+				log.Println("Unmarshal failed:", errMarshal)
+				return errMarshal
+			}
+			result = append(result, p)
+		}
+		return nil
+	}
+
+	// retrieve the data
+	txErr := st.db.View(loadFunc)
+	if txErr != nil {
+		log.Println("Load prints failed:", txErr)
+	}
+
+	return result
 }
 
 func (st *StatTrackerDB) LoadFileStats(fileId string) *stat.DocStat {
@@ -310,6 +415,36 @@ func (st *StatTrackerDB) WriteDailyUserStats(day *stat.DailyUserStat) {
 	if txErr != nil {
 		log.Fatal(txErr)
 	}
+
+	st.updateRollups(day)
+}
+
+// updateRollups merges a changed day into its week, month and year
+func (st *StatTrackerDB) updateRollups(day *stat.DailyUserStat) {
+	date, err := time.Parse("2006-01-02", day.ModDate)
+	if err != nil {
+		log.Println("Rollup date failed:", err)
+		return
+	}
+
+	for _, kind := range stat.RollupKinds {
+		period := stat.PeriodKey(kind, date)
+		rollup := st.LoadRollup(kind, period)
+		if rollup == nil {
+			rollup = stat.NewRollupStat(kind, period)
+		}
+
+		rollup.SetDay(day)
+		st.putJSON(bucketRollups, rollup.Key(), rollup)
+	}
+}
+
+func (st *StatTrackerDB) LoadRollup(kind string, period string) *stat.RollupStat {
+	var result stat.RollupStat
+	if !st.getJSON(bucketRollups, stat.RollupKey(kind, period), &result) {
+		return nil
+	}
+	return &result
 }
 
 func (st *StatTrackerDB) LoadDailyUserStats(shortDate string) *stat.DailyUserStat {
@@ -345,6 +480,327 @@ func (st *StatTrackerDB) LoadDailyUserStats(shortDate string) *stat.DailyUserSta
 	return &result
 }
 
+// putJSON marshals data into a bucket under key
+func (st *StatTrackerDB) putJSON(bucketName []byte, key string, data interface{}) {
+	writeFunc := func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			log.Println("Bucket failed:", err)
+			return err
+		}
+
+		dat, eMarshal := json.Marshal(data)
+		if eMarshal != nil {
+			log.Println("Marhsal failed:", eMarshal)
+			return eMarshal
+		}
+
+		ePut := bucket.Put([]byte(key), dat)
+		if ePut != nil {
+			log.Println("Put failed:", ePut)
+			return ePut
+		}
+
+		return nil
+	}
+
+	// store some data
+	txErr := st.db.Update(writeFunc)
+	if txErr != nil {
+		log.Fatal(txErr)
+	}
+}
+
+// getJSON unmarshals the value under key into result, returns false if missing
+func (st *StatTrackerDB) getJSON(bucketName []byte, key string, result interface{}) bool {
+	loadFunc := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		if bucket == nil {
+			return errors.New("Bucket not found!")
+		}
+
+		dat := bucket.Get([]byte(key))
+		if dat == nil {
+			return errors.New("Key not found")
+		}
+
+		errMarshal := json.Unmarshal(dat, result)
+		if errMarshal != nil {
+			log.Println("Unmarshal failed:", errMarshal)
+			return errMarshal
+		}
+
+		return nil
+	}
+
+	// retrieve the data
+	txErr := st.db.View(loadFunc)
+	return txErr == nil
+}
+
+func (st *StatTrackerDB) WriteTarget(target *stat.Target) {
+	st.putJSON(bucketTargets, target.Id, target)
+}
+
+func (st *StatTrackerDB) LoadTarget(id string) *stat.Target {
+	var result stat.Target
+	if !st.getJSON(bucketTargets, id, &result) {
+		return nil
+	}
+	return &result
+}
+
+func (st *StatTrackerDB) WriteSettings(settings *stat.Settings) {
+	st.putJSON(bucketSettings, settingsKey, settings)
+}
+
+// LoadSettings returns the stored settings or the defaults if none are saved
+func (st *StatTrackerDB) LoadSettings() *stat.Settings {
+	result := stat.DefaultSettings()
+	st.getJSON(bucketSettings, settingsKey, result)
+	return result
+}
+
+func (st *StatTrackerDB) WriteProject(project *stat.Project) {
+	st.putJSON(bucketProjects, project.Id, project)
+}
+
+func (st *StatTrackerDB) LoadProject(id string) *stat.Project {
+	var result stat.Project
+	if !st.getJSON(bucketProjects, id, &result) {
+		return nil
+	}
+	return &result
+}
+
+func (st *StatTrackerDB) LoadProjects() []*stat.Project {
+	result := []*stat.Project{}
+
+	loadFunc := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketProjects)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var p stat.Project
+			errMarshal := json.Unmarshal(v, &p)
+			if errMarshal != nil {
+				log.Println("Unmarshal failed:", errMarshal)
+				return errMarshal
+			}
+			result = append(result, &p)
+		}
+		return nil
+	}
+
+	// retrieve the data
+	txErr := st.db.View(loadFunc)
+	if txErr != nil {
+		log.Println("Load projects failed:", txErr)
+	}
+
+	return result
+}
+
+// WriteDocTerms stores a document's terms and swaps its old terms for the new
+// ones in the corpus document frequencies
+func (st *StatTrackerDB) WriteDocTerms(terms *stat.DocTerms) {
+	writeFunc := func(tx *bolt.Tx) error {
+		termBucket, err := tx.CreateBucketIfNotExists(bucketTerms)
+		if err != nil {
+			log.Println("Bucket failed:", err)
+			return err
+		}
+		corpusBucket, err := tx.CreateBucketIfNotExists(bucketCorpus)
+		if err != nil {
+			log.Println("Bucket failed:", err)
+			return err
+		}
+
+		corpus := stat.NewCorpus()
+		if dat := corpusBucket.Get([]byte(corpusKey)); dat != nil {
+			if eMarshal := json.Unmarshal(dat, corpus); eMarshal != nil {
+				log.Println("Unmarshal failed:", eMarshal)
+				return eMarshal
+			}
+		}
+
+		if dat := termBucket.Get([]byte(terms.FileId)); dat != nil {
+			var prev stat.DocTerms
+			if eMarshal := json.Unmarshal(dat, &prev); eMarshal != nil {
+				log.Println("Unmarshal failed:", eMarshal)
+				return eMarshal
+			}
+			corpus.Remove(&prev)
+		}
+		corpus.Add(terms)
+
+		termDat, eMarshal := json.Marshal(terms)
+		if eMarshal != nil {
+			log.Println("Marhsal failed:", eMarshal)
+			return eMarshal
+		}
+		corpusDat, eMarshal := json.Marshal(corpus)
+		if eMarshal != nil {
+			log.Println("Marhsal failed:", eMarshal)
+			return eMarshal
+		}
+
+		if ePut := termBucket.Put([]byte(terms.FileId), termDat); ePut != nil {
+			log.Println("Put failed:", ePut)
+			return ePut
+		}
+		if ePut := corpusBucket.Put([]byte(corpusKey), corpusDat); ePut != nil {
+			log.Println("Put failed:", ePut)
+			return ePut
+		}
+
+		return nil
+	}
+
+	txErr := st.db.Update(writeFunc)
+	if txErr != nil {
+		log.Fatal(txErr)
+	}
+}
+
+func (st *StatTrackerDB) LoadDocTerms(fileId string) *stat.DocTerms {
+	var result stat.DocTerms
+	if !st.getJSON(bucketTerms, fileId, &result) {
+		return nil
+	}
+	return &result
+}
+
+func (st *StatTrackerDB) WriteBlame(blame *stat.DocBlame) {
+	st.putJSON(bucketBlame, blame.FileId, blame)
+}
+
+func (st *StatTrackerDB) LoadBlame(fileId string) *stat.DocBlame {
+	var result stat.DocBlame
+	if !st.getJSON(bucketBlame, fileId, &result) {
+		return nil
+	}
+	return &result
+}
+
+// WriteCuts replaces the stored cut passages of a file
+func (st *StatTrackerDB) WriteCuts(fileId string, cuts []stat.CutPassage) {
+	writeFunc := func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketCuts)
+		if err != nil {
+			log.Println("Bucket failed:", err)
+			return err
+		}
+
+		// Collect first, bolt cursors do not survive deletes
+		prefix := []byte(fileId + " ")
+		oldKeys := [][]byte{}
+		c := bucket.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, _ = c.Next() {
+			oldKeys = append(oldKeys, append([]byte{}, k...))
+		}
+		for _, k := range oldKeys {
+			if eDel := bucket.Delete(k); eDel != nil {
+				log.Println("Delete failed:", eDel)
+				return eDel
+			}
+		}
+
+		for i, cut := range cuts {
+			dat, eMarshal := json.Marshal(cut)
+			if eMarshal != nil {
+				log.Println("Marhsal failed:", eMarshal)
+				return eMarshal
+			}
+			if ePut := bucket.Put([]byte(cut.Key(i)), dat); ePut != nil {
+				log.Println("Put failed:", ePut)
+				return ePut
+			}
+		}
+
+		return nil
+	}
+
+	// store some data
+	txErr := st.db.Update(writeFunc)
+	if txErr != nil {
+		log.Fatal(txErr)
+	}
+}
+
+// LoadCuts returns every stored cut passage, by file then date
+func (st *StatTrackerDB) LoadCuts() []stat.CutPassage {
+	result := []stat.CutPassage{}
+
+	loadFunc := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketCuts)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var cut stat.CutPassage
+			errMarshal := json.Unmarshal(v, &cut)
+			if errMarshal != nil {
+				log.Println("Unmarshal failed:", errMarshal)
+				return errMarshal
+			}
+			result = append(result, cut)
+		}
+		return nil
+	}
+
+	// retrieve the data
+	txErr := st.db.View(loadFunc)
+	if txErr != nil {
+		log.Println("Load cuts failed:", txErr)
+	}
+
+	return result
+}
+
+// LoadCorpus returns the corpus document frequencies, empty if none are stored
+func (st *StatTrackerDB) LoadCorpus() *stat.Corpus {
+	result := stat.NewCorpus()
+	st.getJSON(bucketCorpus, corpusKey, result)
+	return result
+}
+
+func (st *StatTrackerDB) LoadAllDocTerms() []*stat.DocTerms {
+	result := []*stat.DocTerms{}
+
+	loadFunc := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTerms)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var dt stat.DocTerms
+			errMarshal := json.Unmarshal(v, &dt)
+			if errMarshal != nil {
+				log.Println("Unmarshal failed:", errMarshal)
+				return errMarshal
+			}
+			result = append(result, &dt)
+		}
+		return nil
+	}
+
+	// retrieve the data
+	txErr := st.db.View(loadFunc)
+	if txErr != nil {
+		log.Println("Load terms failed:", txErr)
+	}
+
+	return result
+}
+
 func (st *StatTrackerDB) LoadNextFile(fileId string) *drive.File {
 	var result drive.File
 
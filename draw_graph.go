@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
+	"io"
 	"time"
 
 	"GoDriveTracker/stat"
@@ -20,7 +22,7 @@ func init() {
 //
 // Histograms Charts
 //
-func dailyWordHistChart(filename string, width, height int, days []*stat.DailyUserStat, dateLine []time.Time) {
+func dailyWordHistChart(filename string, width, height int, days []*stat.DailyUserStat, dateLine []time.Time, averages ...[]float64) {
 
 	dest := image.NewRGBA(image.Rect(0, 0, width, height))
 	gc := draw2dimg.NewGraphicContext(dest)
@@ -113,6 +115,27 @@ func dailyWordHistChart(filename string, width, height int, days []*stat.DailyUs
 	}
 	gc.Fill()
 
+	// Averages drawn over the bars on the same scale
+	avgColors := []color.RGBA{{0x00, 0x66, 0xcc, 0xff}, {0xcc, 0x66, 0x00, 0xff}}
+	for a, avg := range averages {
+		if maxVal <= 0 {
+			break
+		}
+
+		gc.SetStrokeColor(avgColors[a%len(avgColors)])
+		gc.SetLineWidth(2)
+		for i := 0; i < len(avg) && i < dayLen; i += 1 {
+			x := float64(i)*xStep + xStep/2
+			y := hf - baseLineY - (hf-baseLineY)*avg[i]/maxVal
+			if i == 0 {
+				gc.MoveTo(x, y)
+			} else {
+				gc.LineTo(x, y)
+			}
+		}
+		gc.Stroke()
+	}
+
 	// Draw Base Line
 	{
 		gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xff})
@@ -137,3 +160,53 @@ func dailyWordHistChart(filename string, width, height int, days []*stat.DailyUs
 
 	draw2dimg.SaveToPngFile(filename, dest)
 }
+
+//
+// Heatmap Charts
+//
+func heatmapChart(w io.Writer, width, height int, hm stat.Heatmap) error {
+
+	dest := image.NewRGBA(image.Rect(0, 0, width, height))
+	gc := draw2dimg.NewGraphicContext(dest)
+
+	labelW := 40.0
+	labelH := 20.0
+	cellW := (float64(width) - labelW) / 24
+	cellH := (float64(height) - labelH) / 7
+
+	// Background
+	gc.SetFillColor(color.RGBA{0xff, 0xff, 0xff, 0xff})
+	draw2dkit.Rectangle(gc, 0, 0, float64(width), float64(height))
+	gc.Fill()
+
+	// Cells, Monday first
+	for row := 0; row < 7; row += 1 {
+		day := (row + 1) % 7
+		for hour := 0; hour < 24; hour += 1 {
+			shade := uint8(0xee)
+			if hm.Max > 0 && hm.Words[day][hour] > 0 {
+				shade = uint8(0xdd - 0xdd*hm.Words[day][hour]/hm.Max)
+			}
+
+			x := labelW + float64(hour)*cellW
+			y := labelH + float64(row)*cellH
+			gc.SetFillColor(color.RGBA{shade, 0xff - (0xff-shade)/3, shade, 0xff})
+			draw2dkit.Rectangle(gc, x+1, y+1, x+cellW-1, y+cellH-1)
+			gc.Fill()
+		}
+	}
+
+	// Labels
+	gc.SetFillColor(color.RGBA{0x44, 0x44, 0x44, 0xff})
+	gc.SetFontData(draw2d.FontData{Name: "Roboto", Family: draw2d.FontFamilySans})
+	gc.SetFontSize(8)
+	for row := 0; row < 7; row += 1 {
+		day := time.Weekday((row + 1) % 7)
+		gc.FillStringAt(day.String()[:3], 4, labelH+float64(row)*cellH+cellH/2+4)
+	}
+	for hour := 0; hour < 24; hour += 3 {
+		gc.FillStringAt(fmt.Sprintf("%02d", hour), labelW+float64(hour)*cellW, labelH-6)
+	}
+
+	return png.Encode(w, dest)
+}
@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	database "GoDriveTracker/database"
@@ -19,7 +20,8 @@ import (
 	web "GoDriveTracker/web"
 )
 
-type CommandFunc func() error
+// CommandFunc runs a console command with the words typed after its name
+type CommandFunc func(args []string) error
 
 var (
 	addr         = flag.String("addr", "127.0.0.1:1667", "Web Address")
@@ -28,6 +30,8 @@ var (
 	templateFldr = flag.String("template", "./templates", "Templates Folder")
 	debug        = flag.Bool("debug", false, "show HTTP traffic")
 	commandFuncs = make(map[string]CommandFunc)
+	userSettings = stat.DefaultSettings()
+	settingsLock sync.RWMutex
 )
 
 func init() {
@@ -35,7 +39,7 @@ func init() {
 
 	switch runtime.GOOS {
 	case "windows":
-		commandFuncs["clear"] = func() error {
+		commandFuncs["clear"] = func(args []string) error {
 			cmd := exec.Command("cmd", "/c", "cls")
 			cmd.Stdout = os.Stdout
 			cmd.Run()
@@ -45,7 +49,7 @@ func init() {
 	case "linux":
 		fallthrough
 	default:
-		commandFuncs["clear"] = func() error {
+		commandFuncs["clear"] = func(args []string) error {
 			print("\033[H\033[2J")
 			return nil
 		}
@@ -53,7 +57,7 @@ func init() {
 }
 
 func main() {
-	commandFuncs["clear"]()
+	commandFuncs["clear"](nil)
 	flag.Parse()
 	if *debug {
 		log.Println("Debug Active")
@@ -69,6 +73,7 @@ func main() {
 	// Setup Database
 	log.Println("Setup Database")
 	db := database.OpenDB(*db)
+	setSettings(db.LoadSettings())
 
 	// Get Identity
 	log.Println("Get Identity")
@@ -108,21 +113,34 @@ func main() {
 		SetupDatabase(wf, db)
 	}
 
-	// REBUILD DEBUG
-	{
+	commandFuncs["rebuild"] = func(args []string) error {
+		RebuildDailyStats(db)
+		return nil
+	}
+	commandFuncs["recalc"] = func(args []string) error {
+		set := *currentSettings()
+		return RecalcStats(db, &set)
+	}
+	commandFuncs["drift"] = func(args []string) error {
+		report, err := styleDrift(db, args)
+		if err != nil {
+			return err
+		}
+		fmt.Println(report)
+		return nil
+	}
 
-		// Generate Daily Stat
-		docs := []*stat.DocStat{}
+	// Stats stored before fingerprints were kept apart are written again once
+	if len(db.LoadPrints()) == 0 {
 		for f := db.LoadNextFileStat(""); f != nil; f = db.LoadNextFileStat(f.FileId) {
-			docs = append(docs, f)
+			db.WriteFileStats(f)
 		}
+	}
 
-		dates := stat.CreateDailyUserStat(docs)
-
-		// Slower but good test (and get sorting from DB)
-		for _, v := range dates {
-			db.WriteDailyUserStats(&v)
-		}
+	// Days stored before words were kept per file need one full rebuild
+	if day := db.LoadNextDailyUserStat(""); day != nil && !day.HasFileWords() {
+		log.Println("Rebuilding daily stats")
+		RebuildDailyStats(db)
 	}
 
 	log.Println("User", userStat.UserID, userStat.Email)
@@ -138,9 +156,25 @@ func main() {
 
 	// Clean up
 	log.Println("Clean up")
+	recounts.Wait()
 	db.CloseDB()
 }
 
+// currentSettings returns the settings in use. A settings struct is never
+// changed once it is in use, saving the settings swaps in a new one.
+func currentSettings() *stat.Settings {
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+	return userSettings
+}
+
+// setSettings puts new settings in use
+func setSettings(set *stat.Settings) {
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	userSettings = set
+}
+
 func commandLoop() {
 	lines := scanForInput()
 
@@ -148,11 +182,15 @@ func commandLoop() {
 		fmt.Println("Enter Command: ")
 		select {
 		case line := <-lines:
-			line = strings.ToLower(line)
+			args := strings.Fields(line)
+			if len(args) == 0 {
+				continue
+			}
+			line = strings.ToLower(args[0])
 
 			valFunc, ok := commandFuncs[line]
 			if ok {
-				err := valFunc()
+				err := valFunc(args[1:])
 
 				if err != nil {
 					log.Printf("Error [%s]: %s", line, err.Error())
@@ -161,7 +199,7 @@ func commandLoop() {
 				return
 			} else {
 				log.Printf("Unknown command: %s", line)
-				listCommands()
+				listCommands(nil)
 			}
 
 		}
@@ -183,7 +221,7 @@ func scanForInput() chan string {
 	return lines
 }
 
-func listCommands() error {
+func listCommands(args []string) error {
 	commandOut := "Commands: "
 	for i := range commandFuncs {
 		commandOut += i + ", "
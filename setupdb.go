@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 
 	database "GoDriveTracker/database"
 	google "GoDriveTracker/google"
@@ -17,6 +19,7 @@ import (
 )
 
 func SetupDatabase(wf *web.WebFace, db *database.StatTrackerDB) {
+	set := currentSettings()
 	webBuf := bytes.NewBufferString("Starting Server")
 	fileCounter := 0
 	numFiles := 0
@@ -49,37 +52,154 @@ func SetupDatabase(wf *web.WebFace, db *database.StatTrackerDB) {
 
 	// Handle per file
 	docStatList := []*stat.DocStat{}
+	prevList := []*stat.DocStat{}
 	numFiles = len(driveFilelist)
 	for ifile, file := range driveFilelist {
 		fileCounter = ifile
 
 		db.WriteFile(file)
-		dStat := FilePullCalc(file, db)
+		prev := db.LoadFileStats(file.Id)
+		dStat, err := FilePullCalc(file, db, set)
+		if err != nil {
+			log.Println(err)
+		}
 
 		db.WriteFileStats(dStat)
 
 		fmt.Fprintf(outBuf, "Stats File Generated: %s... %s %s\n", file.Id[:6], dStat.LastMod[:10], file.Title)
 		docStatList = append(docStatList, dStat)
+		prevList = append(prevList, prev)
+	}
+
+	flagDocs(db, docStatList, set)
+
+	// Update Daily Stat
+	for i, dStat := range docStatList {
+		UpdateDailyStats(db, prevList[i], dStat)
+	}
+
+	wf.RedirectHandler = nil
+}
+
+// flagDocs flags pasted in and copied text now every document is known
+func flagDocs(db *database.StatTrackerDB, docs []*stat.DocStat, set *stat.Settings) {
+	hashes := stat.DocTextHashes(docs)
+	prints := stat.DocPrints(docs)
+	for _, dStat := range docs {
+		stat.FlagImports(dStat, stat.OtherHashes(hashes, dStat.FileId), set)
+		stat.FlagCopies(dStat, prints)
+		db.WriteFileStats(dStat)
 	}
+}
+
+var errRecalcRunning = errors.New("Recalculation already running")
+
+// recalcState stops two recalculations running at once and keeps the error
+// of the last one
+var recalcState struct {
+	sync.Mutex
+	running bool
+	err     error
+}
+
+// RecalcStatus reports if RecalcStats is working and how the last one ended
+func RecalcStatus() (bool, error) {
+	recalcState.Lock()
+	defer recalcState.Unlock()
+	return recalcState.running, recalcState.err
+}
 
-	// Generate Daily Stat
-	dates := stat.CreateDailyUserStat(docStatList)
+// RecalcStats works out the stats of every file again from its stored
+// revisions with set, which must not change while it runs, and updates the
+// days they touch. Revision text not stored yet is fetched once, revisions
+// that fail to fetch are left out and reported in the error. It does nothing
+// if a recalculation is already running.
+func RecalcStats(db *database.StatTrackerDB, set *stat.Settings) error {
+	recalcState.Lock()
+	if recalcState.running {
+		recalcState.Unlock()
+		return errRecalcRunning
+	}
+	recalcState.running = true
+	recalcState.err = nil
+	recalcState.Unlock()
+
+	docs := []*stat.DocStat{}
+	prevs := []*stat.DocStat{}
+	failed := 0
+	for file := db.LoadNextFile(""); file != nil; file = db.LoadNextFile(file.Id) {
+		revs := db.LoadRevisions(file.Id)
+		if len(revs) == 0 {
+			continue
+		}
+		prev := db.LoadFileStats(file.Id)
+		dStat, err := FileCalc(file, revs, prev, db, set)
+		if err != nil {
+			log.Println(err)
+			failed++
+		}
+		if len(dStat.RevList) == 0 {
+			continue
+		}
+		db.WriteFileStats(dStat)
+		docs = append(docs, dStat)
+		prevs = append(prevs, prev)
+	}
+
+	flagDocs(db, docs, set)
+	for i, dStat := range docs {
+		UpdateDailyStats(db, prevs[i], dStat)
+	}
+
+	log.Printf("Recalculated %d files", len(docs))
+
+	var err error
+	if failed > 0 {
+		err = fmt.Errorf("%d files had revisions that could not be fetched, see the log", failed)
+	}
+
+	recalcState.Lock()
+	recalcState.running = false
+	recalcState.err = err
+	recalcState.Unlock()
+	return err
+}
+
+// RebuildDailyStats rewrites every daily stat from the stored file stats
+func RebuildDailyStats(db *database.StatTrackerDB) {
+	docs := []*stat.DocStat{}
+	for f := db.LoadNextFileStat(""); f != nil; f = db.LoadNextFileStat(f.FileId) {
+		docs = append(docs, f)
+	}
+
+	dates := stat.CreateDailyUserStat(docs)
+
+	// Empty out days no file touches anymore
+	for d := db.LoadNextDailyUserStat(""); d != nil; d = db.LoadNextDailyUserStat(d.ModDate) {
+		if _, ok := dates[d.ModDate]; !ok {
+			dates[d.ModDate] = stat.DailyUserStat{ModDate: d.ModDate}
+		}
+	}
 
 	// Slower but good test (and get sorting from DB)
 	for _, v := range dates {
 		db.WriteDailyUserStats(&v)
 	}
 
-	wf.RedirectHandler = nil
+	log.Printf("Rebuilt %d days from %d files", len(dates), len(docs))
 }
 
-func FilePullCalc(file *drive.File, db *database.StatTrackerDB) *stat.DocStat {
-	dStat := stat.DocStat{
-		FileId:  file.Id,
-		Title:   file.Title,
-		LastMod: file.ModifiedDate,
+// UpdateDailyStats merges one changed file into the days it touches,
+// prev is the file stat before the change or nil
+func UpdateDailyStats(db *database.StatTrackerDB, prev *stat.DocStat, doc *stat.DocStat) {
+	days := stat.UpdateDailyUserStat(prev, doc, db.LoadDailyUserStats)
+
+	for _, v := range days {
+		db.WriteDailyUserStats(&v)
 	}
+}
 
+func FilePullCalc(file *drive.File, db *database.StatTrackerDB, set *stat.Settings) (*stat.DocStat, error) {
 	// Get Revisions List
 	revLists, errRev := google.AllRevisions(file.Id)
 
@@ -89,23 +209,112 @@ func FilePullCalc(file *drive.File, db *database.StatTrackerDB) *stat.DocStat {
 
 	for _, r := range revLists {
 		db.WriteRevision(file.Id, r)
+	}
+
+	return FileCalc(file, revLists, db.LoadFileStats(file.Id), db, set)
+}
 
-		rStat := RevisionPullCalc(r)
+// FileCalc works out the stats of a file from its revisions, oldest first.
+// prev is the file stat from before, or nil, and keeps the user's import
+// overrides. Revisions whose text can not be fetched are left out and
+// reported in the error.
+func FileCalc(file *drive.File, revs []*drive.Revision, prev *stat.DocStat, db *database.StatTrackerDB, set *stat.Settings) (*stat.DocStat, error) {
+	dStat := stat.DocStat{
+		FileId:  file.Id,
+		Title:   file.Title,
+		LastMod: file.ModifiedDate,
+	}
+
+	overrides := make(map[string]string)
+	if prev != nil {
+		for _, r := range prev.RevList {
+			overrides[r.RevId] = r.ImportOverride
+		}
+	}
+
+	glossary := fileGlossary(db, file.Id, fileParents(file))
+	blame := &stat.DocBlame{FileId: file.Id}
+	cuts := []stat.CutPassage{}
+
+	prevText := ""
+	skipped := 0
+	var lastErr error
+	for _, r := range revs {
+		rStat, text, err := RevisionPullCalc(db, file.Id, r, prevText, set)
+		if err != nil {
+			log.Println("Skipping", file.Id, "revision", r.Id, err)
+			skipped++
+			lastErr = err
+			continue
+		}
+		rStat.ImportOverride = overrides[rStat.RevId]
+		rStat.Entities = stat.CountEntities(text, glossary)
+		stat.UpdateBlame(blame, rStat, text, set.TokenMode)
+		cuts = append(cuts, stat.FindCuts(file.Id, file.Title, rStat, prevText, text, set)...)
 		dStat.RevList = append(dStat.RevList, rStat)
+		prevText = text
+
+		if len(dStat.RevList) == 1 {
+			dStat.FirstFingerprint = stat.CalcFingerprint(text, set.TokenMode)
+		}
 	}
+	dStat.Fingerprint = stat.CalcFingerprint(prevText, set.TokenMode)
+	stat.TrackCounts(&dStat, storedText(db), set)
+
+	// Latest text feeds the corpus for distinctive words
+	db.WriteDocTerms(stat.CalcDocTerms(file.Id, file.Title, prevText, set))
+	db.WriteBlame(blame)
+	db.WriteCuts(file.Id, cuts)
 
-	return &dStat
+	if skipped > 0 {
+		return &dStat, fmt.Errorf("%s: %d of %d revisions skipped, last error: %s", file.Title, skipped, len(revs), lastErr)
+	}
+	return &dStat, nil
 }
 
-func RevisionPullCalc(rev *drive.Revision) stat.RevStat {
-	rBody, e := google.GetAuth(rev.ExportLinks["text/plain"])
+func getExport(rev *drive.Revision, mimeType string) (string, error) {
+	rBody, e := google.GetAuth(rev.ExportLinks[mimeType])
 	if e != nil {
-		log.Fatalln("Failed to get text file", e.Error())
+		return "", fmt.Errorf("Failed to get %s file: %s", mimeType, e)
 	}
+	defer rBody.Body.Close()
 
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(rBody.Body)
-	bodyStr := buf.String()
+	if _, e := buf.ReadFrom(rBody.Body); e != nil {
+		return "", fmt.Errorf("Failed to read %s file: %s", mimeType, e)
+	}
+	return buf.String(), nil
+}
+
+// revisionText returns an export of a revision, from the database if it was
+// fetched before
+func revisionText(db *database.StatTrackerDB, fileId string, rev *drive.Revision, mimeType string) (string, error) {
+	if text, ok := db.LoadRevisionText(fileId, rev.Id, mimeType); ok {
+		return text, nil
+	}
+
+	text, err := getExport(rev, mimeType)
+	if err != nil {
+		return "", err
+	}
+	db.WriteRevisionText(fileId, rev.Id, mimeType, text)
+	return text, nil
+}
+
+// storedText reads revision text kept in the database, nothing is fetched
+func storedText(db *database.StatTrackerDB) stat.RevText {
+	return func(fileId string, revId string) (string, bool) {
+		return db.LoadRevisionText(fileId, revId, "text/plain")
+	}
+}
+
+// RevisionPullCalc works out the stats of a revision against the text of the
+// one before it and returns its text for the next
+func RevisionPullCalc(db *database.StatTrackerDB, fileId string, rev *drive.Revision, prevText string, set *stat.Settings) (stat.RevStat, string, error) {
+	bodyStr, err := revisionText(db, fileId, rev, "text/plain")
+	if err != nil {
+		return stat.RevStat{}, "", err
+	}
 
 	revStat := stat.RevStat{
 		RevId:    rev.Id,
@@ -113,7 +322,17 @@ func RevisionPullCalc(rev *drive.Revision) stat.RevStat {
 		ModDate:  rev.ModifiedDate,
 	}
 
-	revStat.WordFreq, revStat.WordCount = stat.GetTopWords(bodyStr)
+	stat.CalcRevStat(&revStat, bodyStr, set)
+	stat.CalcChurn(&revStat, prevText, bodyStr, set.TokenMode)
+	stat.CalcEditSpots(&revStat, prevText, bodyStr, set.TokenMode)
+
+	if _, ok := rev.ExportLinks["text/html"]; ok && set.FetchSections {
+		htmlStr, err := revisionText(db, fileId, rev, "text/html")
+		if err != nil {
+			return revStat, bodyStr, err
+		}
+		revStat.Sections = stat.CalcSections(stat.ParseHTMLBlocks(htmlStr), set.TokenMode)
+	}
 
-	return revStat
+	return revStat, bodyStr, nil
 }
@@ -12,6 +12,49 @@ type RevStat struct {
 	WordCount int        `json:"WordCount"`
 	ModDate   string     `json:"ModDate"`
 	WordFreq  []WordPair `json:"WordFreq"`
+	TokenMode string     `json:"TokenMode"`
+
+	ContentWords []WordPair `json:"ContentWords"`
+
+	Script  string         `json:"Script"`
+	Scripts map[string]int `json:"Scripts"`
+
+	Readability Readability `json:"Readability"`
+
+	Bigrams  []WordPair `json:"Bigrams"`
+	Trigrams []WordPair `json:"Trigrams"`
+	Overused []WordPair `json:"Overused"`
+
+	Vocab VocabStat `json:"Vocab"`
+
+	Sections []SectionStat `json:"Sections"`
+
+	TextHash       string `json:"TextHash"`
+	Imported       bool   `json:"Imported"`
+	ImportReason   string `json:"ImportReason"`
+	ImportOverride string `json:"ImportOverride"`
+
+	Chars         int     `json:"Chars"`
+	CharsNoSpaces int     `json:"CharsNoSpaces"`
+	Pages         float64 `json:"Pages"`
+	ReadMinutes   float64 `json:"ReadMinutes"`
+
+	TokensInserted int     `json:"TokensInserted"`
+	TokensDeleted  int     `json:"TokensDeleted"`
+	Churn          float64 `json:"Churn"`
+
+	// Where in the document the revision changed text
+	Edits []EditSpot `json:"Edits"`
+
+	Fillers FillerStat `json:"Fillers"`
+
+	Prose ProseStat `json:"Prose"`
+
+	// Mentions of each project glossary entry
+	Entities map[string]int `json:"Entities"`
+
+	// Counts of the phrases, fillers and echoes flagged anywhere in the doc
+	Tracked *TrackedCounts `json:"Tracked"`
 }
 
 type DocStat struct {
@@ -19,6 +62,35 @@ type DocStat struct {
 	Title   string    `json:"Title"`
 	LastMod string    `json:"LastMod"`
 	RevList []RevStat `json:"RevList"`
+
+	// MinHash of the latest and first revisions for spotting copies
+	Fingerprint      []uint64 `json:"Fingerprint"`
+	FirstFingerprint []uint64 `json:"FirstFingerprint"`
+}
+
+// RevText returns the stored text of a revision, false if there is none
+type RevText func(fileId string, revId string) (string, bool)
+
+// CalcRevStat fills in the text derived stats of a revision
+func CalcRevStat(rev *RevStat, text string, set *Settings) {
+	rev.TokenMode = set.TokenMode
+	rev.TextHash = TextHash(text)
+	rev.WordFreq, rev.WordCount = GetTopWordsMode(text, set.TokenMode)
+	rev.ContentWords = GetTopContentWords(text, set)
+	CalcLength(rev, text, set)
+
+	rev.Scripts = ScriptCounts(GetTokenizer(set.TokenMode)(text))
+	rev.Script = DominantScript(rev.Scripts)
+
+	rev.Readability = CalcReadability(text, set.TokenMode)
+
+	CalcPhrases(rev, text, set)
+
+	rev.Vocab = CalcVocab(text, set.TokenMode)
+
+	rev.Fillers = CalcFillers(text, set)
+
+	rev.Prose = CalcProse(text, set.TokenMode)
 }
 
 func (rev RevStat) GetTime() string {
@@ -0,0 +1,199 @@
+package stat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Share of words two paragraphs must have in common to count as one
+// paragraph edited rather than one cut and another written
+const BlameSimilarity = 0.5
+
+// Most paragraph pairs compared for edits in one revision, past this the
+// unmatched paragraphs count as cut and written
+const MaxBlamePairs = 250000
+
+// Upper bounds in days of the blame age buckets, anything older falls in
+// the bucket after the last
+var BlameAgeDays = []int{1, 7, 30, 90, 365}
+
+// ParagraphBlame is when a paragraph of the latest revision was written and
+// when it was last changed
+type ParagraphBlame struct {
+	Text       string `json:"Text"`
+	FirstRevId string `json:"FirstRevId"`
+	FirstDate  string `json:"FirstDate"`
+	LastRevId  string `json:"LastRevId"`
+	LastDate   string `json:"LastDate"`
+}
+
+// DocBlame annotates every paragraph of a document's latest revision
+type DocBlame struct {
+	FileId     string           `json:"FileId"`
+	RevId      string           `json:"RevId"`
+	Paragraphs []ParagraphBlame `json:"Paragraphs"`
+}
+
+func (pb ParagraphBlame) String() string {
+	return fmt.Sprintf("[%s %s / %s %s] %s", pb.FirstRevId, pb.FirstDate, pb.LastRevId, pb.LastDate, pb.Text)
+}
+
+// Changed is true if the paragraph was edited after it was written
+func (pb ParagraphBlame) Changed() bool {
+	return pb.LastRevId != pb.FirstRevId
+}
+
+// paragraphWords counts the lower case words of a paragraph
+func paragraphWords(para string, tokenize Tokenizer) map[string]int {
+	words := make(map[string]int)
+	for _, w := range tokenize(para) {
+		words[strings.ToLower(w)]++
+	}
+	return words
+}
+
+// paragraphSimilarity is the share of words in common out of the longer
+// paragraph
+func paragraphSimilarity(a, b map[string]int) float64 {
+	shared, totalA, totalB := 0, 0, 0
+	for w, c := range a {
+		totalA += c
+		if cb := b[w]; cb < c {
+			shared += cb
+		} else {
+			shared += c
+		}
+	}
+	for _, c := range b {
+		totalB += c
+	}
+	if totalB > totalA {
+		totalA = totalB
+	}
+	if totalA == 0 {
+		return 0
+	}
+	return float64(shared) / float64(totalA)
+}
+
+// alignParagraphs matches each new paragraph to the old one it came from, -1
+// for new text, and marks the matches that were edited. Unchanged paragraphs
+// match by text wherever they moved, the rest match the most similar
+// unmatched old paragraph.
+func alignParagraphs(old, new []string, mode string) ([]int, []bool) {
+	match := make([]int, len(new))
+	edited := make([]bool, len(new))
+	used := make([]bool, len(old))
+
+	byText := make(map[string][]int)
+	for i, p := range old {
+		byText[p] = append(byText[p], i)
+	}
+	for i, p := range new {
+		match[i] = -1
+		if idx := byText[p]; len(idx) > 0 {
+			match[i] = idx[0]
+			used[idx[0]] = true
+			byText[p] = idx[1:]
+		}
+	}
+
+	restOld := []int{}
+	for i := range old {
+		if !used[i] {
+			restOld = append(restOld, i)
+		}
+	}
+	restNew := []int{}
+	for i := range new {
+		if match[i] < 0 {
+			restNew = append(restNew, i)
+		}
+	}
+	if len(restOld) == 0 || len(restNew) == 0 || len(restOld)*len(restNew) > MaxBlamePairs {
+		return match, edited
+	}
+
+	tokenize := GetTokenizer(mode)
+	oldWords := make(map[int]map[string]int)
+	for _, i := range restOld {
+		oldWords[i] = paragraphWords(old[i], tokenize)
+	}
+	for _, i := range restNew {
+		words := paragraphWords(new[i], tokenize)
+		best, bestSim := -1, BlameSimilarity
+		for _, j := range restOld {
+			if used[j] {
+				continue
+			}
+			if sim := paragraphSimilarity(words, oldWords[j]); sim >= bestSim {
+				best, bestSim = j, sim
+			}
+		}
+		if best >= 0 {
+			match[i] = best
+			edited[i] = true
+			used[best] = true
+		}
+	}
+
+	return match, edited
+}
+
+// UpdateBlame walks the blame on to the text of the next revision. Kept
+// paragraphs keep their dates, edited ones take the revision as their last
+// change and new ones are written in it.
+func UpdateBlame(blame *DocBlame, rev RevStat, text string, mode string) {
+	old := make([]string, len(blame.Paragraphs))
+	for i, pb := range blame.Paragraphs {
+		old[i] = pb.Text
+	}
+	paras := splitParagraphs(text)
+	match, edited := alignParagraphs(old, paras, mode)
+
+	result := make([]ParagraphBlame, len(paras))
+	for i, para := range paras {
+		pb := ParagraphBlame{Text: para, FirstRevId: rev.RevId, FirstDate: rev.ModDate, LastRevId: rev.RevId, LastDate: rev.ModDate}
+		if match[i] >= 0 {
+			prev := blame.Paragraphs[match[i]]
+			pb.FirstRevId, pb.FirstDate = prev.FirstRevId, prev.FirstDate
+			if !edited[i] {
+				pb.LastRevId, pb.LastDate = prev.LastRevId, prev.LastDate
+			}
+		}
+		result[i] = pb
+	}
+
+	blame.RevId = rev.RevId
+	blame.Paragraphs = result
+}
+
+// BlameAge is the age bucket of a revision date, len(BlameAgeDays) if older
+// than the last bucket
+func BlameAge(modDate string, now time.Time) int {
+	date, err := time.Parse("2006-01-02T15:04:05.000Z", modDate)
+	if err != nil {
+		return len(BlameAgeDays)
+	}
+	days := now.Sub(date).Hours() / 24
+	for i, limit := range BlameAgeDays {
+		if days < float64(limit) {
+			return i
+		}
+	}
+	return len(BlameAgeDays)
+}
+
+// BlameAgeLabels names the blame age buckets
+func BlameAgeLabels() []string {
+	labels := []string{}
+	for _, limit := range BlameAgeDays {
+		if limit == 1 {
+			labels = append(labels, "under a day")
+		} else {
+			labels = append(labels, fmt.Sprintf("under %d days", limit))
+		}
+	}
+	return append(labels, fmt.Sprintf("%d days or more", BlameAgeDays[len(BlameAgeDays)-1]))
+}
@@ -0,0 +1,133 @@
+package stat
+
+const (
+	// Past this many edits between two revisions the diff falls back to
+	// comparing word counts, a full diff would take too long
+	MaxDiffEdits = 4000
+
+	// Share of changed tokens that are deletions below which a day is
+	// drafting and above which it is revising
+	DraftingShare = 0.2
+	RevisingShare = 0.4
+
+	DayDrafting = "drafting"
+	DayRevising = "revising"
+	DayMixed    = "mixed"
+)
+
+// DiffTokens counts the tokens inserted and deleted going from a to b
+func DiffTokens(a, b []string) (inserted int, deleted int) {
+	// Most revisions only touch the middle of a document
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	d := editDistance(a, b, MaxDiffEdits)
+	if d < 0 {
+		return bagDiff(a, b)
+	}
+
+	// Every edit is an insert or a delete so the split follows from the sizes
+	inserted = (d + len(b) - len(a)) / 2
+	deleted = d - inserted
+	return inserted, deleted
+}
+
+// editDistance is the Myers insert/delete distance, -1 if over maxEdits
+func editDistance(a, b []string, maxEdits int) int {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return 0
+	}
+	if maxEdits > max {
+		maxEdits = max
+	}
+
+	v := make([]int, 2*max+2)
+	for d := 0; d <= maxEdits; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+
+			if x >= n && y >= m {
+				return d
+			}
+		}
+	}
+
+	return -1
+}
+
+// bagDiff ignores order and compares how often each token is used
+func bagDiff(a, b []string) (inserted int, deleted int) {
+	counts := make(map[string]int)
+	for _, w := range a {
+		counts[w]--
+	}
+	for _, w := range b {
+		counts[w]++
+	}
+
+	for _, c := range counts {
+		if c > 0 {
+			inserted += c
+		} else {
+			deleted -= c
+		}
+	}
+	return inserted, deleted
+}
+
+// CalcChurn compares a revision with the one before it, churn is the tokens
+// inserted plus deleted divided by the size of the document
+func CalcChurn(rev *RevStat, prevText string, text string, tokenMode string) {
+	tokenize := GetTokenizer(tokenMode)
+	prevTokens := tokenize(prevText)
+	tokens := tokenize(text)
+
+	rev.TokensInserted, rev.TokensDeleted = DiffTokens(prevTokens, tokens)
+
+	size := len(tokens)
+	if len(prevTokens) > size {
+		size = len(prevTokens)
+	}
+	if size > 0 {
+		rev.Churn = float64(rev.TokensInserted+rev.TokensDeleted) / float64(size)
+	}
+}
+
+// DayKind classifies a day as drafting, revising or mixed by how much of the
+// change was deletion. Days from before token diffs fall back to word counts.
+func (day DailyUserStat) DayKind() string {
+	inserted, deleted := day.TokensInserted, day.TokensDeleted
+	if inserted == 0 && deleted == 0 {
+		inserted, deleted = day.WordAdd, -day.WordSub
+	}
+	if inserted+deleted <= 0 {
+		return ""
+	}
+
+	share := float64(deleted) / float64(inserted+deleted)
+	switch {
+	case share < DraftingShare:
+		return DayDrafting
+	case share > RevisingShare:
+		return DayRevising
+	}
+	return DayMixed
+}
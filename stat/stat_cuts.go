@@ -0,0 +1,162 @@
+package stat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Default smallest cut in words worth keeping
+const DefaultCutWords = 50
+
+// CutPassage is a run of text deleted from a document in one revision
+type CutPassage struct {
+	FileId  string `json:"FileId"`
+	Title   string `json:"Title"`
+	RevId   string `json:"RevId"`
+	ModDate string `json:"ModDate"`
+	Words   int    `json:"Words"`
+	Text    string `json:"Text"`
+}
+
+func (cp CutPassage) String() string {
+	return fmt.Sprintf("[%s %s %s] %d words: %s", cp.Title, cp.RevId, cp.ModDate, cp.Words, cp.Text)
+}
+
+// Key is unique per passage and sorts the cuts of a file by revision
+func (cp CutPassage) Key(index int) string {
+	return fmt.Sprintf("%s %s %s %04d", cp.FileId, cp.ModDate, cp.RevId, index)
+}
+
+// CutsByDate sorts cut passages newest first
+type CutsByDate []CutPassage
+
+func (a CutsByDate) Len() int           { return len(a) }
+func (a CutsByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a CutsByDate) Less(i, j int) bool { return a[i].ModDate > a[j].ModDate }
+
+// FindCuts collects the passages of CutWords or more deleted between two
+// revision texts. Sentences of changed paragraphs with nothing like them
+// left in the new text are cut, and join into one passage while they follow
+// each other in the old text. A revision with too many sentences to compare
+// only cuts the paragraphs removed whole.
+func FindCuts(fileId, title string, rev RevStat, prevText, text string, set *Settings) []CutPassage {
+	cuts := []CutPassage{}
+	if set.CutWords <= 0 || prevText == "" {
+		return cuts
+	}
+
+	old := splitParagraphs(prevText)
+	paras := splitParagraphs(text)
+	match, edited := alignParagraphs(old, paras, set.TokenMode)
+
+	// Where each old paragraph went, -1 if it was cut
+	kept := make([]int, len(old))
+	for i := range kept {
+		kept[i] = -1
+	}
+	for i, m := range match {
+		if m >= 0 {
+			kept[m] = i
+		}
+	}
+
+	tokenize := GetTokenizer(set.TokenMode)
+	var run strings.Builder
+	runWords := 0
+
+	flush := func() {
+		if runWords >= set.CutWords {
+			cuts = append(cuts, CutPassage{
+				FileId:  fileId,
+				Title:   title,
+				RevId:   rev.RevId,
+				ModDate: rev.ModDate,
+				Words:   runWords,
+				Text:    run.String(),
+			})
+		}
+		run.Reset()
+		runWords = 0
+	}
+	// add puts cut text on the run, on a new line if it starts a paragraph
+	add := func(s string, newPara bool) {
+		if run.Len() > 0 {
+			if newPara {
+				run.WriteString("\n")
+			} else {
+				run.WriteString(" ")
+			}
+		}
+		run.WriteString(s)
+		runWords += len(tokenize(s))
+	}
+
+	// Sentences of the new paragraphs that are not unchanged, where text
+	// from edited or cut paragraphs may have gone
+	changed := []map[string]int{}
+	for i, para := range paras {
+		if match[i] < 0 || edited[i] {
+			for _, sentence := range splitSentences(para) {
+				changed = append(changed, paragraphWords(sentence, tokenize))
+			}
+		}
+	}
+	oldSentences := make([][]string, len(old))
+	count := 0
+	for i, para := range old {
+		if kept[i] < 0 || edited[kept[i]] {
+			oldSentences[i] = splitSentences(para)
+			count += len(oldSentences[i])
+		}
+	}
+	// Too many to compare, so only paragraphs gone as a whole are cut
+	wholeOnly := count*len(changed) > MaxBlamePairs
+
+	for i := range old {
+		if kept[i] >= 0 && (wholeOnly || !edited[kept[i]]) {
+			flush()
+			continue
+		}
+
+		newPara := true
+		for _, sentence := range oldSentences[i] {
+			if !wholeOnly && sentenceKept(paragraphWords(sentence, tokenize), changed) {
+				flush()
+			} else {
+				add(sentence, newPara)
+			}
+			newPara = false
+		}
+	}
+	flush()
+
+	return cuts
+}
+
+// sentenceKept is true if one of the new sentences is the same as or an edit
+// of the old one
+func sentenceKept(words map[string]int, newSentences []map[string]int) bool {
+	for _, ns := range newSentences {
+		if paragraphSimilarity(words, ns) >= BlameSimilarity {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchCuts picks out the cuts whose text or title holds the query, ignoring
+// case, all of them for a blank query
+func SearchCuts(cuts []CutPassage, query string) []CutPassage {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return cuts
+	}
+
+	result := []CutPassage{}
+	for _, cp := range cuts {
+		if strings.Contains(strings.ToLower(cp.Text), query) || strings.Contains(strings.ToLower(cp.Title), query) {
+			result = append(result, cp)
+		}
+	}
+	return result
+}
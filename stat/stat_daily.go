@@ -5,55 +5,173 @@ import (
 )
 
 type DailyUserStat struct {
-	WordAdd  int                 `json:"WordAdd"`
-	WordSub  int                 `json:"WordSub"`
-	ModDate  string              `json:"ModDate"`
-	FileRevs map[string][]string `json:"FileRevList"`
+	WordAdd      int `json:"WordAdd"`
+	WordSub      int `json:"WordSub"`
+	WordImported int `json:"WordImported"`
+	CharAdd      int `json:"CharAdd"`
+	CharSub      int `json:"CharSub"`
+	// Token level edits, churn is summed over the revisions of the day
+	TokensInserted int                 `json:"TokensInserted"`
+	TokensDeleted  int                 `json:"TokensDeleted"`
+	Churn          float64             `json:"Churn"`
+	ModDate        string              `json:"ModDate"`
+	FileRevs       map[string][]string `json:"FileRevList"`
+	FileWords      map[string]FileDay  `json:"FileWords"`
+}
+
+// FileDay is one file's share of a day
+type FileDay struct {
+	WordAdd        int      `json:"WordAdd"`
+	WordSub        int      `json:"WordSub"`
+	WordImported   int      `json:"WordImported"`
+	CharAdd        int      `json:"CharAdd"`
+	CharSub        int      `json:"CharSub"`
+	TokensInserted int      `json:"TokensInserted"`
+	TokensDeleted  int      `json:"TokensDeleted"`
+	Churn          float64  `json:"Churn"`
+	Revs           []string `json:"-"`
 }
 
 func (day DailyUserStat) String() string {
 	return fmt.Sprintf("[%s] Words %d / %d with following edits { %s }", day.ModDate, day.WordAdd, day.WordSub, day.FileRevs)
 }
 
+// CreateDailyUserStat builds every day from scratch out of the docs
 func CreateDailyUserStat(docStatList []*DocStat) (dates map[string]DailyUserStat) {
 
 	dates = make(map[string]DailyUserStat)
 
 	for _, fileStat := range docStatList {
-		prev := 0
-
-		// Faster to do all dates then merge
-		for _, v := range fileStat.RevList {
-			shortDate := v.ModDate[:10]
-
+		for shortDate, fd := range docDays(fileStat) {
 			dv, ok := dates[shortDate]
 			if !ok {
-				dv = DailyUserStat{
-					WordAdd:  0,
-					WordSub:  0,
-					ModDate:  shortDate,
-					FileRevs: map[string][]string{fileStat.FileId: {v.RevId}},
-				}
-			} else {
-				revSubList, okFile := dv.FileRevs[fileStat.FileId]
-				if !okFile {
-					dv.FileRevs[fileStat.FileId] = []string{v.RevId}
-				} else {
-					dv.FileRevs[fileStat.FileId] = append(revSubList, v.RevId)
-				}
+				dv = DailyUserStat{ModDate: shortDate}
 			}
+			dv.setFile(fileStat.FileId, fd)
+			dates[shortDate] = dv
+		}
+	}
+
+	return dates
+}
+
+// UpdateDailyUserStat recomputes only the days a changed doc touches and
+// merges them into the stored days, leaving other files alone. prev is the
+// doc as it was before the change (or nil) so days it has left are cleared,
+// load returns the stored day or nil.
+func UpdateDailyUserStat(prev *DocStat, doc *DocStat, load func(shortDate string) *DailyUserStat) (days []DailyUserStat) {
+	fileDays := docDays(doc)
+
+	affected := make(map[string]bool)
+	for shortDate := range fileDays {
+		affected[shortDate] = true
+	}
+	if prev != nil {
+		for shortDate := range docDays(prev) {
+			affected[shortDate] = true
+		}
+	}
+
+	for shortDate := range affected {
+		dv := DailyUserStat{ModDate: shortDate}
+		if stored := load(shortDate); stored != nil {
+			dv = *stored
+		}
+
+		dv.setFile(doc.FileId, fileDays[shortDate])
+		days = append(days, dv)
+	}
+
+	return days
+}
+
+// HasFileWords is false for days stored before words were kept per file,
+// those can only be fixed with a full rebuild
+func (day DailyUserStat) HasFileWords() bool {
+	return len(day.FileRevs) == 0 || day.FileWords != nil
+}
 
-			diff := v.WordCount - prev
+// docDays works out a doc's share of each day it has revisions on
+func docDays(fileStat *DocStat) map[string]*FileDay {
+	days := make(map[string]*FileDay)
+	prev := 0
+	prevChars := 0
+
+	for _, v := range fileStat.RevList {
+		shortDate := v.ModDate[:10]
+
+		fd, ok := days[shortDate]
+		if !ok {
+			fd = &FileDay{}
+			days[shortDate] = fd
+		}
+		fd.Revs = append(fd.Revs, v.RevId)
+
+		// Imported text is kept out of the totals
+		diff := v.WordCount - prev
+		charDiff := v.Chars - prevChars
+		if v.IsImported() {
+			fd.WordImported = fd.WordImported + diff
+		} else {
 			if diff >= 0 {
-				dv.WordAdd = dv.WordAdd + diff
+				fd.WordAdd = fd.WordAdd + diff
 			} else {
-				dv.WordSub = dv.WordSub + diff
+				fd.WordSub = fd.WordSub + diff
+			}
+
+			if charDiff >= 0 {
+				fd.CharAdd = fd.CharAdd + charDiff
+			} else {
+				fd.CharSub = fd.CharSub + charDiff
 			}
-			dates[shortDate] = dv
 
-			prev = v.WordCount
+			fd.TokensInserted = fd.TokensInserted + v.TokensInserted
+			fd.TokensDeleted = fd.TokensDeleted + v.TokensDeleted
+			fd.Churn = fd.Churn + v.Churn
 		}
+
+		prev = v.WordCount
+		prevChars = v.Chars
 	}
 
-	return dates
+	return days
+}
+
+// setFile replaces a file's share of the day, nil removes the file
+func (day *DailyUserStat) setFile(fileId string, fd *FileDay) {
+	if day.FileRevs == nil {
+		day.FileRevs = make(map[string][]string)
+	}
+	if day.FileWords == nil {
+		day.FileWords = make(map[string]FileDay)
+	}
+
+	if fd == nil {
+		delete(day.FileRevs, fileId)
+		delete(day.FileWords, fileId)
+	} else {
+		day.FileRevs[fileId] = fd.Revs
+		words := *fd
+		words.Revs = nil
+		day.FileWords[fileId] = words
+	}
+
+	day.WordAdd = 0
+	day.WordSub = 0
+	day.WordImported = 0
+	day.CharAdd = 0
+	day.CharSub = 0
+	day.TokensInserted = 0
+	day.TokensDeleted = 0
+	day.Churn = 0
+	for _, w := range day.FileWords {
+		day.WordAdd += w.WordAdd
+		day.WordSub += w.WordSub
+		day.WordImported += w.WordImported
+		day.CharAdd += w.CharAdd
+		day.CharSub += w.CharSub
+		day.TokensInserted += w.TokensInserted
+		day.TokensDeleted += w.TokensDeleted
+		day.Churn += w.Churn
+	}
 }
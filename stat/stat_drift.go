@@ -0,0 +1,225 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Number of words listed as gaining or losing share in a drift report
+const DriftShiftWords = 15
+
+// StyleProfile is the word distribution and sentence length of some text
+type StyleProfile struct {
+	Label             string
+	Docs              int
+	Words             int
+	Freq              map[string]int
+	Sentences         int
+	AvgSentenceLength float64
+}
+
+// WordShift is how a word's share of the profile changed
+type WordShift struct {
+	Word   string
+	ShareA float64
+	ShareB float64
+	Change float64
+}
+
+// DriftReport compares two style profiles
+type DriftReport struct {
+	A              StyleProfile
+	B              StyleProfile
+	Divergence     float64
+	Gained         []WordShift
+	Lost           []WordShift
+	SentenceChange float64
+}
+
+type wordShiftByChange []WordShift
+
+func (a wordShiftByChange) Len() int      { return len(a) }
+func (a wordShiftByChange) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a wordShiftByChange) Less(i, j int) bool {
+	if a[i].Change == a[j].Change {
+		return a[i].Word < a[j].Word
+	}
+	return a[i].Change > a[j].Change
+}
+
+// Percent turns a share into a percentage
+func (ws WordShift) Percent(share float64) float64 {
+	return share * 100
+}
+
+func (ws WordShift) String() string {
+	return fmt.Sprintf("%s %.2f%% -> %.2f%%", ws.Word, ws.Percent(ws.ShareA), ws.Percent(ws.ShareB))
+}
+
+func (p StyleProfile) String() string {
+	return fmt.Sprintf("%s: %d docs, %d words, %d sentences of %.1f words", p.Label, p.Docs, p.Words, p.Sentences, p.AvgSentenceLength)
+}
+
+func (r DriftReport) String() string {
+	s := fmt.Sprintf("%s\n%s\nDivergence %.3f, sentence length %+.1f words\n", r.A, r.B, r.Divergence, r.SentenceChange)
+	s += "Gained:"
+	for _, w := range r.Gained {
+		s += fmt.Sprintf("\n\t%s", w)
+	}
+	s += "\nLost:"
+	for _, w := range r.Lost {
+		s += fmt.Sprintf("\n\t%s", w)
+	}
+	return s
+}
+
+// addRev counts every word of a revision's stored text into the profile.
+// Without the text it falls back to the word lists of the revision, a word in
+// both the top words and content words is only counted once.
+func (p *StyleProfile) addRev(fileId string, rev RevStat, text RevText) {
+	if p.Freq == nil {
+		p.Freq = make(map[string]int)
+	}
+
+	if text != nil {
+		if t, ok := text(fileId, rev.RevId); ok {
+			counts, _ := wordCountMode(t, rev.TokenMode)
+			for w, c := range counts {
+				p.Freq[w] += c
+			}
+			p.addCounts(rev)
+			return
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, wp := range rev.WordFreq {
+		counts[wp.Word] = wp.Count
+	}
+	for _, wp := range rev.ContentWords {
+		if wp.Count > counts[wp.Word] {
+			counts[wp.Word] = wp.Count
+		}
+	}
+	for w, c := range counts {
+		p.Freq[w] += c
+	}
+	p.addCounts(rev)
+}
+
+func (p *StyleProfile) addCounts(rev RevStat) {
+	p.Docs++
+	p.Words += rev.WordCount
+	p.Sentences += rev.Readability.Sentences
+	p.AvgSentenceLength += rev.Readability.AvgSentenceLength * float64(rev.Readability.Sentences)
+}
+
+func (p *StyleProfile) finish() {
+	if p.Sentences > 0 {
+		p.AvgSentenceLength = p.AvgSentenceLength / float64(p.Sentences)
+	} else {
+		p.AvgSentenceLength = 0
+	}
+}
+
+// ProfileRange profiles the last revision of each doc edited between from and
+// to, both inclusive short dates. text may be nil to use the word lists.
+func ProfileRange(docs []*DocStat, from string, to string, text RevText) StyleProfile {
+	p := StyleProfile{Label: from + " to " + to, Freq: make(map[string]int)}
+
+	for _, doc := range docs {
+		var last *RevStat
+		for i, rev := range doc.RevList {
+			shortDate := rev.ModDate[:10]
+			if shortDate >= from && shortDate <= to {
+				last = &doc.RevList[i]
+			}
+		}
+		if last != nil {
+			p.addRev(doc.FileId, *last, text)
+		}
+	}
+
+	p.finish()
+	return p
+}
+
+// ProfileDoc profiles the latest revision of a doc
+func ProfileDoc(doc *DocStat, text RevText) StyleProfile {
+	p := StyleProfile{Label: doc.Title, Freq: make(map[string]int)}
+	if len(doc.RevList) > 0 {
+		p.addRev(doc.FileId, doc.RevList[len(doc.RevList)-1], text)
+	}
+	p.finish()
+	return p
+}
+
+func shares(freq map[string]int) map[string]float64 {
+	total := 0
+	for _, c := range freq {
+		total += c
+	}
+
+	result := make(map[string]float64)
+	if total == 0 {
+		return result
+	}
+	for w, c := range freq {
+		result[w] = float64(c) / float64(total)
+	}
+	return result
+}
+
+// CompareProfiles scores the Jensen-Shannon divergence of two profiles, 0 for
+// the same distribution up to 1 for no words in common, and lists the words
+// that moved most
+func CompareProfiles(a StyleProfile, b StyleProfile) DriftReport {
+	r := DriftReport{A: a, B: b, SentenceChange: b.AvgSentenceLength - a.AvgSentenceLength}
+
+	shareA := shares(a.Freq)
+	shareB := shares(b.Freq)
+
+	words := make(map[string]bool)
+	for w := range shareA {
+		words[w] = true
+	}
+	for w := range shareB {
+		words[w] = true
+	}
+
+	shifts := []WordShift{}
+	for w := range words {
+		pa, pb := shareA[w], shareB[w]
+		m := (pa + pb) / 2
+		if pa > 0 {
+			r.Divergence += pa * math.Log2(pa/m) / 2
+		}
+		if pb > 0 {
+			r.Divergence += pb * math.Log2(pb/m) / 2
+		}
+
+		if pa != pb {
+			shifts = append(shifts, WordShift{Word: w, ShareA: pa, ShareB: pb, Change: pb - pa})
+		}
+	}
+
+	// An empty side has nothing to compare
+	if len(shareA) == 0 || len(shareB) == 0 {
+		r.Divergence = 0
+	}
+
+	sort.Sort(wordShiftByChange(shifts))
+	for _, ws := range shifts {
+		if ws.Change > 0 && len(r.Gained) < DriftShiftWords {
+			r.Gained = append(r.Gained, ws)
+		}
+	}
+	for i := len(shifts) - 1; i >= 0; i-- {
+		if shifts[i].Change < 0 && len(r.Lost) < DriftShiftWords {
+			r.Lost = append(r.Lost, shifts[i])
+		}
+	}
+
+	return r
+}
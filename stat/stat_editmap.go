@@ -0,0 +1,147 @@
+package stat
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Number of bands the edit map splits a document into, 5% each
+const EditMapBands = 20
+
+// EditSpot is how many words changed at a point in a revision, Position is
+// the percent of the way through the document
+type EditSpot struct {
+	Position int `json:"Position"`
+	Words    int `json:"Words"`
+}
+
+// EditMap is words changed per day and per band of the document, the first
+// band is the start
+type EditMap struct {
+	From  string
+	To    string
+	Days  []string
+	Words [][EditMapBands]int
+	Max   int
+	Total int
+}
+
+func (em EditMap) String() string {
+	return fmt.Sprintf("Edit map %s to %s, %d words changed, busiest %d", em.From, em.To, em.Total, em.Max)
+}
+
+// paragraphOffsets is the number of words before each paragraph and the total
+func paragraphOffsets(paras []string, tokenize Tokenizer) ([]int, []int, int) {
+	offsets := make([]int, len(paras))
+	lengths := make([]int, len(paras))
+	total := 0
+	for i, para := range paras {
+		offsets[i] = total
+		lengths[i] = len(tokenize(para))
+		total += lengths[i]
+	}
+	return offsets, lengths, total
+}
+
+// CalcEditSpots finds where in the document a revision changed text.
+// Written and edited paragraphs are placed in the new text, cut ones where
+// they were in the old, one spot per percent.
+func CalcEditSpots(rev *RevStat, prevText, text string, mode string) {
+	tokenize := GetTokenizer(mode)
+	old := splitParagraphs(prevText)
+	paras := splitParagraphs(text)
+	match, edited := alignParagraphs(old, paras, mode)
+
+	spots := make(map[int]int)
+	position := func(offset, total int) int {
+		if total == 0 {
+			return 0
+		}
+		return 100 * offset / total
+	}
+
+	oldOffsets, oldLengths, oldTotal := paragraphOffsets(old, tokenize)
+	offsets, lengths, total := paragraphOffsets(paras, tokenize)
+
+	kept := make([]bool, len(old))
+	for i, m := range match {
+		if m >= 0 {
+			kept[m] = true
+		}
+
+		words := 0
+		switch {
+		case m < 0:
+			words = lengths[i]
+		case edited[i]:
+			inserted, deleted := DiffTokens(tokenize(old[m]), tokenize(paras[i]))
+			words = inserted + deleted
+		}
+		if words > 0 {
+			spots[position(offsets[i], total)] += words
+		}
+	}
+	for i := range old {
+		if !kept[i] && oldLengths[i] > 0 {
+			spots[position(oldOffsets[i], oldTotal)] += oldLengths[i]
+		}
+	}
+
+	rev.Edits = []EditSpot{}
+	for pos, words := range spots {
+		rev.Edits = append(rev.Edits, EditSpot{Position: pos, Words: words})
+	}
+	sort.Sort(EditSpotsByPosition(rev.Edits))
+}
+
+// EditSpotsByPosition sorts spots from the start of the document
+type EditSpotsByPosition []EditSpot
+
+func (a EditSpotsByPosition) Len() int           { return len(a) }
+func (a EditSpotsByPosition) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a EditSpotsByPosition) Less(i, j int) bool { return a[i].Position < a[j].Position }
+
+// DocEditMap adds up the changes of every day from the first revision of the
+// document to the last, leaving out imported text
+func DocEditMap(doc *DocStat) EditMap {
+	em := EditMap{}
+	if len(doc.RevList) == 0 {
+		return em
+	}
+
+	first, errFirst := time.Parse(shortDateFormat, doc.RevList[0].ModDate[:10])
+	last, errLast := time.Parse(shortDateFormat, doc.RevList[len(doc.RevList)-1].ModDate[:10])
+	if errFirst != nil || errLast != nil {
+		return em
+	}
+	em.From = first.Format(shortDateFormat)
+	em.To = last.Format(shortDateFormat)
+
+	dayIndex := make(map[string]int)
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		dayIndex[d.Format(shortDateFormat)] = len(em.Days)
+		em.Days = append(em.Days, d.Format(shortDateFormat))
+	}
+	em.Words = make([][EditMapBands]int, len(em.Days))
+
+	for _, rev := range doc.RevList {
+		day, ok := dayIndex[rev.ModDate[:10]]
+		if !ok || rev.IsImported() {
+			continue
+		}
+		for _, spot := range rev.Edits {
+			band := spot.Position * EditMapBands / 100
+			if band >= EditMapBands {
+				band = EditMapBands - 1
+			}
+			em.Words[day][band] += spot.Words
+			em.Total += spot.Words
+			if em.Words[day][band] > em.Max {
+				em.Max = em.Words[day][band]
+			}
+		}
+	}
+
+	return em
+}
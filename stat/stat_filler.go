@@ -0,0 +1,131 @@
+package stat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Default number of words within which a repeat is an echo
+const DefaultEchoWindow = 10
+
+// Default crutch words, an entry starting with - matches a word ending
+var DefaultFillerWords = []string{
+	"just", "really", "very", "actually", "basically", "literally", "quite",
+	"rather", "somewhat", "somehow", "simply", "totally", "suddenly", "-ly",
+}
+
+// Words ending in ly that are not adverbs
+var lyNotAdverbs = map[string]bool{
+	"only": true, "family": true, "early": true, "reply": true, "apply": true,
+	"supply": true, "holy": true, "ugly": true, "july": true, "italy": true,
+	"belly": true, "bully": true, "jolly": true, "rely": true, "silly": true,
+	"lonely": true, "lovely": true, "friendly": true, "likely": true, "daily": true,
+	"ally": true, "fly": true, "sly": true, "lily": true, "assembly": true,
+}
+
+// FillerStat counts crutch words and close repeats in a revision
+type FillerStat struct {
+	Total     int        `json:"Total"`
+	Counts    []WordPair `json:"Counts"`
+	Echoes    int        `json:"Echoes"`
+	EchoWords []WordPair `json:"EchoWords"`
+}
+
+func (fs FillerStat) String() string {
+	return fmt.Sprintf("%d fillers %v, %d echoes %v", fs.Total, fs.Counts, fs.Echoes, fs.EchoWords)
+}
+
+// isFiller checks a normalised word against the filler list
+func isFiller(word string, fillers map[string]bool, suffixes []string) bool {
+	if fillers[word] {
+		return true
+	}
+	for _, suffix := range suffixes {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) && !lyNotAdverbs[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// CalcFillers counts the filler words of the text and the echoes, a content
+// word repeated within EchoWindow words of its last use
+func CalcFillers(text string, set *Settings) FillerStat {
+	fillerCounts, echoCounts, pos := countFillers(text, set)
+
+	fs := FillerStat{}
+	for _, c := range fillerCounts {
+		fs.Total += c
+	}
+	for _, c := range echoCounts {
+		fs.Echoes += c
+	}
+	fs.Counts = topWordPairFromMap(fillerCounts, pos, 20, 1)
+	fs.EchoWords = topWordPairFromMap(echoCounts, pos, 10, 1)
+
+	return fs
+}
+
+// countFillers counts every filler and echoed word and the words read
+func countFillers(text string, set *Settings) (map[string]int, map[string]int, int) {
+	fillers := make(map[string]bool)
+	suffixes := []string{}
+	for _, f := range set.FillerWords {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if strings.HasPrefix(f, "-") && len(f) > 1 {
+			suffixes = append(suffixes, f[1:])
+		} else if f != "" {
+			fillers[f] = true
+		}
+	}
+	stop := StopWordSet(set.StopLanguage, set.StopWords)
+
+	fillerCounts := make(map[string]int)
+	echoCounts := make(map[string]int)
+	lastSeen := make(map[string]int)
+
+	pos := 0
+	for _, w := range normaliseWords(GetTokenizer(set.TokenMode)(text)) {
+		if w == "" {
+			continue
+		}
+		pos++
+
+		if isFiller(w, fillers, suffixes) {
+			fillerCounts[w]++
+		}
+
+		if stop[w] || set.EchoWindow <= 0 {
+			continue
+		}
+
+		key := w
+		if set.Stemming {
+			key = Stem(w)
+		}
+		if last, ok := lastSeen[key]; ok && pos-last <= set.EchoWindow {
+			echoCounts[w]++
+		}
+		lastSeen[key] = pos
+	}
+
+	return fillerCounts, echoCounts, pos
+}
+
+// DocFillerTrends follows each filler word across the revisions of a doc
+func DocFillerTrends(doc *DocStat) []PhraseTrend {
+	return wordPairTrends(doc, func(rev RevStat) []WordPair {
+		return rev.Fillers.Counts
+	}, func(tc *TrackedCounts) map[string]int {
+		return tc.Fillers
+	})
+}
+
+// DocEchoTrends follows each echoed word across the revisions of a doc
+func DocEchoTrends(doc *DocStat) []PhraseTrend {
+	return wordPairTrends(doc, func(rev RevStat) []WordPair {
+		return rev.Fillers.EchoWords
+	}, func(tc *TrackedCounts) map[string]int {
+		return tc.Echoes
+	})
+}
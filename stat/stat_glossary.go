@@ -0,0 +1,251 @@
+package stat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	EntityCharacter = "character"
+	EntityPlace     = "place"
+	EntityTerm      = "term"
+)
+
+var EntityKinds = []string{EntityCharacter, EntityPlace, EntityTerm}
+
+// GlossaryEntry is a name tracked through a project's documents
+type GlossaryEntry struct {
+	Name          string   `json:"Name"`
+	Kind          string   `json:"Kind"`
+	Aliases       []string `json:"Aliases"`
+	CaseSensitive bool     `json:"CaseSensitive"`
+}
+
+// EntityPoint is an entity's count at a revision, or at the end of a day
+type EntityPoint struct {
+	Date  string
+	RevId string
+	Count int
+}
+
+// EntitySeries is an entity's mentions over time
+type EntitySeries struct {
+	Entry  GlossaryEntry
+	Points []EntityPoint
+	First  *EntityPoint
+	Last   *EntityPoint
+	Peak   int
+	Latest int
+}
+
+func (ge GlossaryEntry) String() string {
+	return fmt.Sprintf("%s (%s) %v", ge.Name, ge.Kind, ge.Aliases)
+}
+
+// Names is the name followed by the aliases
+func (ge GlossaryEntry) Names() []string {
+	names := []string{ge.Name}
+	for _, a := range ge.Aliases {
+		if a = strings.TrimSpace(a); a != "" {
+			names = append(names, a)
+		}
+	}
+	return names
+}
+
+// AddEntry adds or replaces a glossary entry by name
+func (p *Project) AddEntry(entry GlossaryEntry) {
+	for i, e := range p.Glossary {
+		if e.Name == entry.Name {
+			p.Glossary[i] = entry
+			return
+		}
+	}
+	p.Glossary = append(p.Glossary, entry)
+}
+
+// RemoveEntry takes a name out of the glossary
+func (p *Project) RemoveEntry(name string) {
+	for i, e := range p.Glossary {
+		if e.Name == name {
+			p.Glossary = append(p.Glossary[:i], p.Glossary[i+1:]...)
+			return
+		}
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// countName counts whole word matches of name in text and blanks them out so
+// a shorter alias inside a longer one is not counted twice
+func countName(text string, name string) (int, string) {
+	if name == "" {
+		return 0, text
+	}
+
+	count := 0
+	var blanked strings.Builder
+	copied := 0
+	from := 0
+
+	for {
+		i := strings.Index(text[from:], name)
+		if i < 0 {
+			break
+		}
+		start := from + i
+		end := start + len(name)
+
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || !isWordRune(before)) && (end == len(text) || !isWordRune(after)) {
+			count++
+			blanked.WriteString(text[copied:start])
+			blanked.WriteString(strings.Repeat(" ", len(name)))
+			copied = end
+			from = end
+		} else {
+			from = start + 1
+		}
+	}
+
+	if count == 0 {
+		return 0, text
+	}
+	blanked.WriteString(text[copied:])
+	return count, blanked.String()
+}
+
+// CountEntities counts every glossary entry in the text, aliases included
+func CountEntities(text string, glossary []GlossaryEntry) map[string]int {
+	if len(glossary) == 0 {
+		return nil
+	}
+
+	lower := strings.ToLower(text)
+	result := make(map[string]int)
+
+	for _, entry := range glossary {
+		names := entry.Names()
+		sort.Sort(sort.Reverse(byLength(names)))
+
+		search := lower
+		if entry.CaseSensitive {
+			search = text
+		}
+
+		total := 0
+		for _, name := range names {
+			if !entry.CaseSensitive {
+				name = strings.ToLower(name)
+			}
+			var n int
+			n, search = countName(search, name)
+			total += n
+		}
+		result[entry.Name] = total
+	}
+
+	return result
+}
+
+// RecountEntities counts the glossary again in every revision of a doc after
+// the glossary changed. text returns the stored text of a revision, revisions
+// without one keep their old counts.
+func RecountEntities(doc *DocStat, glossary []GlossaryEntry, text func(revId string) (string, bool)) {
+	for i := range doc.RevList {
+		rev := &doc.RevList[i]
+		if t, ok := text(rev.RevId); ok {
+			rev.Entities = CountEntities(t, glossary)
+		}
+	}
+}
+
+type byLength []string
+
+func (a byLength) Len() int           { return len(a) }
+func (a byLength) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byLength) Less(i, j int) bool { return len(a[i]) < len(a[j]) }
+
+func (es *EntitySeries) finish() {
+	for i := range es.Points {
+		p := &es.Points[i]
+		if p.Count > 0 {
+			if es.First == nil {
+				es.First = p
+			}
+			es.Last = p
+		}
+		if p.Count > es.Peak {
+			es.Peak = p.Count
+		}
+	}
+	if len(es.Points) > 0 {
+		es.Latest = es.Points[len(es.Points)-1].Count
+	}
+}
+
+// DocEntitySeries follows each glossary entry through the revisions of a doc
+func DocEntitySeries(doc *DocStat, glossary []GlossaryEntry) []EntitySeries {
+	result := []EntitySeries{}
+	for _, entry := range glossary {
+		es := EntitySeries{Entry: entry}
+		for _, rev := range doc.RevList {
+			es.Points = append(es.Points, EntityPoint{Date: rev.ModDate[:10], RevId: rev.RevId, Count: rev.Entities[entry.Name]})
+		}
+		es.finish()
+		result = append(result, es)
+	}
+	return result
+}
+
+// ProjectEntitySeries sums each entry over the docs by day, using the last
+// revision of every doc standing on that day. The revision id of a point is
+// the doc and revision that last changed on that day.
+func ProjectEntitySeries(docs []*DocStat, glossary []GlossaryEntry) []EntitySeries {
+	dates := []string{}
+	seen := make(map[string]bool)
+	for _, doc := range docs {
+		for _, rev := range doc.RevList {
+			d := rev.ModDate[:10]
+			if !seen[d] {
+				seen[d] = true
+				dates = append(dates, d)
+			}
+		}
+	}
+	sort.Strings(dates)
+
+	result := []EntitySeries{}
+	for _, entry := range glossary {
+		es := EntitySeries{Entry: entry}
+		for _, date := range dates {
+			p := EntityPoint{Date: date}
+			for _, doc := range docs {
+				var standing *RevStat
+				for i := range doc.RevList {
+					if doc.RevList[i].ModDate[:10] > date {
+						break
+					}
+					standing = &doc.RevList[i]
+				}
+				if standing == nil {
+					continue
+				}
+				p.Count += standing.Entities[entry.Name]
+				if standing.ModDate[:10] == date {
+					p.RevId = doc.Title + " rev " + standing.RevId
+				}
+			}
+			es.Points = append(es.Points, p)
+		}
+		es.finish()
+		result = append(result, es)
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+)
+
+// Heatmap is words added by weekday (Sunday first) and hour of day
+type Heatmap struct {
+	Location string
+	From     string
+	To       string
+	Words    [7][24]int
+	Max      int
+	Total    int
+}
+
+func (hm Heatmap) String() string {
+	return fmt.Sprintf("Heatmap %s to %s (%s) %d words, busiest hour %d", hm.From, hm.To, hm.Location, hm.Total, hm.Max)
+}
+
+// CalcHeatmap adds up words added per revision, at the local time of the
+// revision, for revisions on local dates from..to inclusive
+func CalcHeatmap(docs []*DocStat, from, to time.Time, loc *time.Location) Heatmap {
+	hm := Heatmap{
+		Location: loc.String(),
+		From:     from.Format(shortDateFormat),
+		To:       to.Format(shortDateFormat),
+	}
+
+	for _, doc := range docs {
+		prev := 0
+		for _, rev := range doc.RevList {
+			diff := rev.WordCount - prev
+			prev = rev.WordCount
+
+			modTime, err := time.Parse("2006-01-02T15:04:05.000Z", rev.ModDate)
+			if err != nil || diff <= 0 || rev.IsImported() {
+				continue
+			}
+
+			local := modTime.In(loc)
+			localDate := local.Format(shortDateFormat)
+			if localDate < hm.From || localDate > hm.To {
+				continue
+			}
+
+			hm.Words[local.Weekday()][local.Hour()] += diff
+			hm.Total += diff
+		}
+	}
+
+	for _, day := range hm.Words {
+		for _, v := range day {
+			if v > hm.Max {
+				hm.Max = v
+			}
+		}
+	}
+
+	return hm
+}
@@ -0,0 +1,124 @@
+package stat
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ImportOverrideNone     = ""
+	ImportOverrideImported = "imported"
+	ImportOverrideCounted  = "counted"
+)
+
+// TextHash fingerprints text ignoring whitespace differences
+func TextHash(s string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(strings.Fields(s), " "))))
+}
+
+// IsImported is the heuristic flag unless the user has overridden it
+func (rev RevStat) IsImported() bool {
+	switch rev.ImportOverride {
+	case ImportOverrideImported:
+		return true
+	case ImportOverrideCounted:
+		return false
+	}
+	return rev.Imported
+}
+
+// TextSource is the first time a document held a given text
+type TextSource struct {
+	Title   string
+	ModDate string
+}
+
+// FlagImports marks revisions that look pasted in rather than written: a
+// jump of PasteWords or more at faster than PasteWPM, a first revision of
+// PasteWords or more, or text another document already held.
+// otherHashes maps text hashes to the doc they came from.
+func FlagImports(doc *DocStat, otherHashes map[string]TextSource, set *Settings) {
+	prev := 0
+	var prevTime time.Time
+
+	for i := range doc.RevList {
+		rev := &doc.RevList[i]
+		diff := rev.WordCount - prev
+		prev = rev.WordCount
+
+		rev.Imported = false
+		rev.ImportReason = ""
+
+		modTime, err := time.Parse("2006-01-02T15:04:05.000Z", rev.ModDate)
+		if err != nil {
+			continue
+		}
+		lastTime := prevTime
+		prevTime = modTime
+
+		if diff <= 0 {
+			continue
+		}
+
+		if src, ok := otherHashes[rev.TextHash]; ok && rev.TextHash != "" && src.ModDate < rev.ModDate {
+			rev.Imported = true
+			rev.ImportReason = fmt.Sprintf("identical to %s", src.Title)
+			continue
+		}
+
+		if diff < set.PasteWords {
+			continue
+		}
+
+		if i == 0 {
+			rev.Imported = true
+			rev.ImportReason = fmt.Sprintf("%d words in first revision", diff)
+			continue
+		}
+
+		minutes := modTime.Sub(lastTime).Minutes()
+		if minutes <= 0 || float64(diff)/minutes > float64(set.PasteWPM) {
+			rev.Imported = true
+			rev.ImportReason = fmt.Sprintf("%d words in %.0f seconds", diff, modTime.Sub(lastTime).Seconds())
+		}
+	}
+}
+
+// DocTextHashes maps the text hash of every revision of the docs to the
+// first time each doc held that text
+func DocTextHashes(docs []*DocStat) map[string]map[string]TextSource {
+	result := make(map[string]map[string]TextSource)
+	for _, doc := range docs {
+		for _, rev := range doc.RevList {
+			if rev.TextHash == "" {
+				continue
+			}
+			if result[rev.TextHash] == nil {
+				result[rev.TextHash] = make(map[string]TextSource)
+			}
+			if src, ok := result[rev.TextHash][doc.FileId]; !ok || rev.ModDate < src.ModDate {
+				result[rev.TextHash][doc.FileId] = TextSource{Title: doc.Title, ModDate: rev.ModDate}
+			}
+		}
+	}
+	return result
+}
+
+// OtherHashes picks out the earliest source of each hash among documents
+// other than fileId
+func OtherHashes(hashes map[string]map[string]TextSource, fileId string) map[string]TextSource {
+	result := make(map[string]TextSource)
+	for hash, files := range hashes {
+		for id, src := range files {
+			if id == fileId {
+				continue
+			}
+			if prev, ok := result[hash]; !ok || src.ModDate < prev.ModDate {
+				result[hash] = src
+			}
+		}
+	}
+	return result
+}
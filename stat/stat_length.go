@@ -0,0 +1,50 @@
+package stat
+
+import (
+	"unicode"
+)
+
+const (
+	// Standard manuscript page, 12pt Courier double spaced
+	DefaultWordsPerPage = 250
+	// Average adult silent reading speed
+	DefaultReadingWPM = 230
+)
+
+// CharCounts counts characters with and without whitespace, line breaks and
+// byte order marks are not counted at all
+func CharCounts(text string) (chars int, noSpaces int) {
+	for _, c := range text {
+		if c == '\n' || c == '\r' || c == '\uFEFF' {
+			continue
+		}
+		chars++
+		if !unicode.IsSpace(c) {
+			noSpaces++
+		}
+	}
+	return chars, noSpaces
+}
+
+// Pages estimates the manuscript pages for a number of words
+func (set *Settings) Pages(words int) float64 {
+	if set.WordsPerPage <= 0 {
+		return float64(words) / DefaultWordsPerPage
+	}
+	return float64(words) / float64(set.WordsPerPage)
+}
+
+// ReadMinutes estimates how long a number of words takes to read
+func (set *Settings) ReadMinutes(words int) float64 {
+	if set.ReadingWPM <= 0 {
+		return float64(words) / DefaultReadingWPM
+	}
+	return float64(words) / float64(set.ReadingWPM)
+}
+
+// CalcLength fills in the character counts, pages and reading time
+func CalcLength(rev *RevStat, text string, set *Settings) {
+	rev.Chars, rev.CharsNoSpaces = CharCounts(text)
+	rev.Pages = set.Pages(rev.WordCount)
+	rev.ReadMinutes = set.ReadMinutes(rev.WordCount)
+}
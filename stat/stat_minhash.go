@@ -0,0 +1,190 @@
+package stat
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// Number of hashes kept in a fingerprint
+	MinHashSize = 64
+	// Words per shingle
+	ShingleSize = 5
+
+	// Similarity over which a new doc's first revision is a copy of another doc
+	CopyThreshold = 0.8
+	// Similarity over which two docs share a lot of text
+	OverlapThreshold = 0.3
+
+	RelatedCopyOf   = "copy of"
+	RelatedCopiedTo = "copied to"
+	RelatedOverlap  = "overlaps"
+)
+
+// RelatedDoc is another document sharing text with this one
+type RelatedDoc struct {
+	FileId     string
+	Title      string
+	Kind       string
+	Similarity float64
+}
+
+type relatedBySimilarity []RelatedDoc
+
+func (a relatedBySimilarity) Len() int      { return len(a) }
+func (a relatedBySimilarity) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a relatedBySimilarity) Less(i, j int) bool {
+	return a[i].Similarity > a[j].Similarity
+}
+
+// Percent is the similarity out of 100
+func (r RelatedDoc) Percent() float64 {
+	return r.Similarity * 100
+}
+
+func (r RelatedDoc) String() string {
+	return fmt.Sprintf("%s %s (%.0f%%)", r.Kind, r.Title, r.Percent())
+}
+
+// DocPrint is what finding copies needs of a doc, stored apart from its stats
+// so it can be loaded for every doc cheaply
+type DocPrint struct {
+	FileId           string   `json:"FileId"`
+	Title            string   `json:"Title"`
+	Created          string   `json:"Created"`
+	Fingerprint      []uint64 `json:"Fingerprint"`
+	FirstFingerprint []uint64 `json:"FirstFingerprint"`
+}
+
+// Print is the fingerprints of a doc and when its first revision was made
+func (doc *DocStat) Print() DocPrint {
+	p := DocPrint{FileId: doc.FileId, Title: doc.Title, Fingerprint: doc.Fingerprint, FirstFingerprint: doc.FirstFingerprint}
+	if len(doc.RevList) > 0 {
+		p.Created = doc.RevList[0].ModDate
+	}
+	return p
+}
+
+// DocPrints is the print of each doc
+func DocPrints(docs []*DocStat) []DocPrint {
+	prints := make([]DocPrint, len(docs))
+	for i, doc := range docs {
+		prints[i] = doc.Print()
+	}
+	return prints
+}
+
+// mix64 is the splitmix64 finaliser, it spreads a hash into a new one
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// CalcFingerprint is a MinHash of the word shingles of the text, nil if the
+// text has no words
+func CalcFingerprint(text string, tokenMode string) []uint64 {
+	words := []string{}
+	for _, w := range normaliseWords(GetTokenizer(tokenMode)(text)) {
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	fp := make([]uint64, MinHashSize)
+	for k := range fp {
+		fp[k] = math.MaxUint64
+	}
+
+	size := ShingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sh := h.Sum64()
+
+		for k := range fp {
+			if v := mix64(sh ^ mix64(uint64(k+1))); v < fp[k] {
+				fp[k] = v
+			}
+		}
+	}
+
+	return fp
+}
+
+// Similarity estimates the share of shingles two fingerprints have in common
+func Similarity(a []uint64, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// RelatedDocs finds the docs this one was copied from, was copied to, or
+// shares a lot of its latest text with, most similar first
+func RelatedDocs(doc DocPrint, prints []DocPrint) []RelatedDoc {
+	result := []RelatedDoc{}
+	if doc.Created == "" {
+		return result
+	}
+
+	for _, other := range prints {
+		if other.FileId == doc.FileId || other.Created == "" {
+			continue
+		}
+
+		r := RelatedDoc{FileId: other.FileId, Title: other.Title}
+		if other.Created < doc.Created {
+			r.Kind = RelatedCopyOf
+			r.Similarity = math.Max(Similarity(doc.FirstFingerprint, other.Fingerprint), Similarity(doc.FirstFingerprint, other.FirstFingerprint))
+		} else {
+			r.Kind = RelatedCopiedTo
+			r.Similarity = math.Max(Similarity(other.FirstFingerprint, doc.Fingerprint), Similarity(other.FirstFingerprint, doc.FirstFingerprint))
+		}
+
+		if r.Similarity < CopyThreshold {
+			r.Kind = RelatedOverlap
+			r.Similarity = Similarity(doc.Fingerprint, other.Fingerprint)
+			if r.Similarity < OverlapThreshold {
+				continue
+			}
+		}
+
+		result = append(result, r)
+	}
+
+	sort.Sort(relatedBySimilarity(result))
+	return result
+}
+
+// FlagCopies marks the first revision of a doc copied from another as
+// imported so the copied words are not counted again
+func FlagCopies(doc *DocStat, prints []DocPrint) {
+	for _, r := range RelatedDocs(doc.Print(), prints) {
+		if r.Kind == RelatedCopyOf {
+			doc.RevList[0].Imported = true
+			doc.RevList[0].ImportReason = fmt.Sprintf("copy of %s (%.0f%% similar)", r.Title, r.Percent())
+			return
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package stat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Phrases must appear this often before they can be flagged as overused
+const PhraseMinCount = 3
+
+// Sort by latest count then phrase
+type PhraseTrendByLatest []PhraseTrend
+
+func (a PhraseTrendByLatest) Len() int      { return len(a) }
+func (a PhraseTrendByLatest) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a PhraseTrendByLatest) Less(i, j int) bool {
+	if a[i].Latest != a[j].Latest {
+		return a[i].Latest > a[j].Latest
+	}
+	return a[i].Phrase < a[j].Phrase
+}
+
+type PhraseTrend struct {
+	Phrase  string `json:"Phrase"`
+	Counts  []int  `json:"Counts"`
+	Peak    int    `json:"Peak"`
+	Latest  int    `json:"Latest"`
+	Reduced bool   `json:"Reduced"`
+}
+
+func (pt PhraseTrend) String() string {
+	return fmt.Sprintf("'%s' peak %d now %d %v", pt.Phrase, pt.Peak, pt.Latest, pt.Counts)
+}
+
+// ngramCounts counts runs of n words, never across a sentence break. Phrases
+// made only of stop words are skipped.
+func ngramCounts(s string, n int, mode string, stop map[string]bool) map[string]int {
+	counts := make(map[string]int)
+	tokenize := GetTokenizer(mode)
+
+	trimF := func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	}
+
+	for _, para := range splitParagraphs(s) {
+		for _, sentence := range splitSentences(para) {
+			words := tokenize(sentence)
+			for i := range words {
+				words[i] = strings.ToLower(strings.TrimFunc(words[i], trimF))
+			}
+
+			for i := 0; i+n <= len(words); i++ {
+				gram := words[i : i+n]
+				skip := true
+				for _, w := range gram {
+					if w == "" {
+						skip = true
+						break
+					}
+					if !stop[w] {
+						skip = false
+					}
+				}
+				if !skip {
+					counts[strings.Join(gram, " ")]++
+				}
+			}
+		}
+	}
+
+	return counts
+}
+
+// overusedPhrases returns phrases used more than rate times per 1000 words
+func overusedPhrases(grams []map[string]int, wordCount int, rate float64) []WordPair {
+	limit := rate * float64(wordCount) / 1000
+
+	m := make(map[string]int)
+	for _, g := range grams {
+		for k, v := range g {
+			if v >= PhraseMinCount && float64(v) > limit {
+				m[k] = v
+			}
+		}
+	}
+
+	return topWordPairFromMap(m, wordCount, -1, PhraseMinCount)
+}
+
+// CalcPhrases fills in the bigrams, trigrams and overused phrases of a revision
+func CalcPhrases(rev *RevStat, s string, set *Settings) {
+	stop := StopWordSet(set.StopLanguage, set.StopWords)
+
+	bi := ngramCounts(s, 2, set.TokenMode, stop)
+	tri := ngramCounts(s, 3, set.TokenMode, stop)
+
+	rev.Bigrams = topWordPairFromMap(bi, rev.WordCount, 10, 2)
+	rev.Trigrams = topWordPairFromMap(tri, rev.WordCount, 10, 2)
+	rev.Overused = overusedPhrases([]map[string]int{bi, tri}, rev.WordCount, set.PhraseRate)
+}
+
+// TrackedCounts are the real counts in a revision of every phrase, filler and
+// echoed word flagged in any revision of the doc, so a word that drops out of
+// a revision's list is not taken as gone
+type TrackedCounts struct {
+	Phrases map[string]int `json:"Phrases"`
+	Fillers map[string]int `json:"Fillers"`
+	Echoes  map[string]int `json:"Echoes"`
+}
+
+// TrackCounts fills in the tracked counts of every revision with stored text
+func TrackCounts(doc *DocStat, text RevText, set *Settings) {
+	phrases := make(map[string]bool)
+	fillers := make(map[string]bool)
+	echoes := make(map[string]bool)
+	for _, rev := range doc.RevList {
+		for _, p := range rev.Overused {
+			phrases[p.Word] = true
+		}
+		for _, p := range rev.Fillers.Counts {
+			fillers[p.Word] = true
+		}
+		for _, p := range rev.Fillers.EchoWords {
+			echoes[p.Word] = true
+		}
+	}
+
+	stop := StopWordSet(set.StopLanguage, set.StopWords)
+	pickCounts := func(counts map[string]int, keep map[string]bool) map[string]int {
+		result := make(map[string]int)
+		for w := range keep {
+			if counts[w] > 0 {
+				result[w] = counts[w]
+			}
+		}
+		return result
+	}
+
+	for i := range doc.RevList {
+		rev := &doc.RevList[i]
+		t, ok := text(doc.FileId, rev.RevId)
+		if !ok {
+			rev.Tracked = nil
+			continue
+		}
+
+		grams := ngramCounts(t, 2, set.TokenMode, stop)
+		for k, v := range ngramCounts(t, 3, set.TokenMode, stop) {
+			grams[k] = v
+		}
+		fillerCounts, echoCounts, _ := countFillers(t, set)
+
+		rev.Tracked = &TrackedCounts{
+			Phrases: pickCounts(grams, phrases),
+			Fillers: pickCounts(fillerCounts, fillers),
+			Echoes:  pickCounts(echoCounts, echoes),
+		}
+	}
+}
+
+// DocPhraseTrends follows every phrase flagged in any revision across the
+// whole revision list. A revision where it was not flagged counts as 0 unless
+// its tracked counts are known.
+func DocPhraseTrends(doc *DocStat) []PhraseTrend {
+	return wordPairTrends(doc, func(rev RevStat) []WordPair {
+		return rev.Overused
+	}, func(tc *TrackedCounts) map[string]int {
+		return tc.Phrases
+	})
+}
+
+// wordPairTrends follows each word picked from any revision across the list,
+// revisions with tracked counts use those in place of the picked lists when
+// tracked is not nil
+func wordPairTrends(doc *DocStat, pick func(rev RevStat) []WordPair, tracked func(tc *TrackedCounts) map[string]int) []PhraseTrend {
+	trends := make(map[string]*PhraseTrend)
+	revCount := len(doc.RevList)
+
+	for i, rev := range doc.RevList {
+		for _, p := range pick(rev) {
+			pt, ok := trends[p.Word]
+			if !ok {
+				pt = &PhraseTrend{Phrase: p.Word, Counts: make([]int, revCount)}
+				trends[p.Word] = pt
+			}
+			pt.Counts[i] = p.Count
+		}
+	}
+
+	for i, rev := range doc.RevList {
+		if rev.Tracked == nil || tracked == nil {
+			continue
+		}
+		counts := tracked(rev.Tracked)
+		for w, pt := range trends {
+			pt.Counts[i] = counts[w]
+		}
+	}
+
+	result := []PhraseTrend{}
+	for _, pt := range trends {
+		for _, c := range pt.Counts {
+			if c > pt.Peak {
+				pt.Peak = c
+			}
+		}
+		pt.Latest = pt.Counts[revCount-1]
+		pt.Reduced = pt.Latest < pt.Peak
+		result = append(result, *pt)
+	}
+
+	sort.Sort(PhraseTrendByLatest(result))
+
+	return result
+}
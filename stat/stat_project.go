@@ -0,0 +1,203 @@
+package stat
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Project groups documents, either listed by hand or every doc in a Drive folder
+type Project struct {
+	Id       string   `json:"Id"`
+	Name     string   `json:"Name"`
+	FolderId string   `json:"FolderId"`
+	FileIds  []string `json:"FileIds"`
+
+	// Names counted in every revision of the project's documents
+	Glossary []GlossaryEntry `json:"Glossary"`
+}
+
+// ProjectStat is the project totals built from its member documents
+type ProjectStat struct {
+	Project   *Project
+	Docs      []*DocStat
+	WordCount int
+	WordAdd   int
+	WordSub   int
+	Days      []DailyUserStat
+	TopWords  []WordPair
+}
+
+// ProjectDay is the words one project got on a day
+type ProjectDay struct {
+	ProjectId string
+	Name      string
+	WordAdd   int
+	WordSub   int
+	FileIds   []string
+}
+
+func (p Project) String() string {
+	return fmt.Sprintf("[%s] %s folder '%s' with %d files", p.Id, p.Name, p.FolderId, len(p.FileIds))
+}
+
+// TargetId is the id project targets are stored under
+func (p Project) TargetId() string {
+	return "project:" + p.Id
+}
+
+// IsMember checks the manual list and the Drive folder
+func (p Project) IsMember(fileId string, parentIds []string) bool {
+	for _, f := range p.FileIds {
+		if f == fileId {
+			return true
+		}
+	}
+	if p.FolderId == "" {
+		return false
+	}
+	for _, parent := range parentIds {
+		if parent == p.FolderId {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFile adds a document to the manual list
+func (p *Project) AddFile(fileId string) {
+	for _, f := range p.FileIds {
+		if f == fileId {
+			return
+		}
+	}
+	p.FileIds = append(p.FileIds, fileId)
+}
+
+// RemoveFile takes a document off the manual list
+func (p *Project) RemoveFile(fileId string) {
+	for i, f := range p.FileIds {
+		if f == fileId {
+			p.FileIds = append(p.FileIds[:i], p.FileIds[i+1:]...)
+			return
+		}
+	}
+}
+
+// SumWordPoints adds the word counts of several documents, carrying each
+// document's last count forward over days it was not edited
+func SumWordPoints(pointLists [][]WordPoint) []WordPoint {
+	dateSet := make(map[string]bool)
+	for _, points := range pointLists {
+		for _, p := range points {
+			dateSet[p.Date] = true
+		}
+	}
+
+	dates := []string{}
+	for d := range dateSet {
+		dates = append(dates, d)
+	}
+	sort.Strings(dates)
+
+	result := make([]WordPoint, len(dates))
+	next := make([]int, len(pointLists))
+	current := make([]int, len(pointLists))
+	for i, d := range dates {
+		total := 0
+		for j, points := range pointLists {
+			for next[j] < len(points) && points[next[j]].Date <= d {
+				current[j] = points[next[j]].Words
+				next[j]++
+			}
+			total += current[j]
+		}
+		result[i] = WordPoint{Date: d, Words: total}
+	}
+
+	return result
+}
+
+// DayByProject breaks a day down by project using its FileWords. Files in no
+// project are gathered under an empty project id.
+func DayByProject(day *DailyUserStat, fileProjects map[string][]*Project) []ProjectDay {
+	byId := make(map[string]*ProjectDay)
+	order := []string{}
+
+	for fileId, words := range day.FileWords {
+
+		projects := fileProjects[fileId]
+		if len(projects) == 0 {
+			projects = []*Project{{Name: "(no project)"}}
+		}
+
+		for _, p := range projects {
+			pd, ok := byId[p.Id]
+			if !ok {
+				pd = &ProjectDay{ProjectId: p.Id, Name: p.Name}
+				byId[p.Id] = pd
+				order = append(order, p.Id)
+			}
+			pd.WordAdd += words.WordAdd
+			pd.WordSub += words.WordSub
+			pd.FileIds = append(pd.FileIds, fileId)
+		}
+	}
+
+	sort.Strings(order)
+	result := []ProjectDay{}
+	for _, id := range order {
+		result = append(result, *byId[id])
+	}
+	return result
+}
+
+// CalcProjectStat totals up the member documents of a project. Top words add
+// up the full term counts of each doc's latest text where terms has them,
+// otherwise the word lists of its latest revision.
+func CalcProjectStat(project *Project, docs []*DocStat, terms map[string]*DocTerms) ProjectStat {
+	ps := ProjectStat{
+		Project: project,
+		Docs:    docs,
+	}
+
+	words := make(map[string]int)
+	for _, doc := range docs {
+		if len(doc.RevList) == 0 {
+			continue
+		}
+		latest := doc.RevList[len(doc.RevList)-1]
+		ps.WordCount += latest.WordCount
+
+		if dt, ok := terms[doc.FileId]; ok && dt != nil {
+			for w, c := range dt.Terms {
+				words[w] += c
+			}
+			continue
+		}
+
+		topList := latest.ContentWords
+		if len(topList) == 0 {
+			topList = latest.WordFreq
+		}
+		for _, wp := range topList {
+			words[wp.Word] += wp.Count
+		}
+	}
+	ps.TopWords = topWordPairFromMap(words, ps.WordCount, 10, 1)
+
+	dates := CreateDailyUserStat(docs)
+	keys := []string{}
+	for k := range dates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		day := dates[k]
+		ps.WordAdd += day.WordAdd
+		ps.WordSub += day.WordSub
+		ps.Days = append(ps.Days, day)
+	}
+
+	return ps
+}
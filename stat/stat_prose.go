@@ -0,0 +1,160 @@
+package stat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Width in words of each paragraph length histogram bucket, the last bucket
+// holds everything longer
+const (
+	ParagraphHistStep    = 20
+	ParagraphHistBuckets = 8
+)
+
+// ProseStat splits a revision into dialogue, the words inside quotation
+// marks, and narration, with the spread of paragraph lengths
+type ProseStat struct {
+	DialogueWords      int     `json:"DialogueWords"`
+	NarrationWords     int     `json:"NarrationWords"`
+	DialogueParagraphs int     `json:"DialogueParagraphs"`
+	Paragraphs         int     `json:"Paragraphs"`
+	AvgParagraph       float64 `json:"AvgParagraph"`
+	LongestParagraph   int     `json:"LongestParagraph"`
+	ParagraphHist      []int   `json:"ParagraphHist"`
+}
+
+func (ps ProseStat) String() string {
+	return fmt.Sprintf("%d dialogue, %d narration words, %d paragraphs averaging %.1f words",
+		ps.DialogueWords, ps.NarrationWords, ps.Paragraphs, ps.AvgParagraph)
+}
+
+// DialogueShare is the part of all words spoken, 0 to 1
+func (ps ProseStat) DialogueShare() float64 {
+	return dialogueShare(ps.DialogueWords, ps.DialogueWords+ps.NarrationWords)
+}
+
+// DialogueRatio is dialogue words per narration word
+func (ps ProseStat) DialogueRatio() float64 {
+	if ps.NarrationWords == 0 {
+		return 0
+	}
+	return float64(ps.DialogueWords) / float64(ps.NarrationWords)
+}
+
+// DialogueShare is the part of the section's own words spoken, 0 to 1
+func (sec SectionStat) DialogueShare() float64 {
+	return dialogueShare(sec.DialogueWords, sec.Words)
+}
+
+func dialogueShare(dialogue, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(dialogue) / float64(total)
+}
+
+// ParagraphHistLabels names the paragraph histogram buckets
+func ParagraphHistLabels() []string {
+	labels := make([]string, ParagraphHistBuckets)
+	for i := range labels {
+		if i == ParagraphHistBuckets-1 {
+			labels[i] = fmt.Sprintf("%d+", i*ParagraphHistStep)
+		} else {
+			labels[i] = fmt.Sprintf("%d-%d", i*ParagraphHistStep, (i+1)*ParagraphHistStep-1)
+		}
+	}
+	return labels
+}
+
+// Opening marks and the mark that closes each
+var quoteClose = map[rune]rune{
+	'"': '"', '“': '”', '„': '“', '«': '»', '»': '«', '‘': '’', '\'': '\'', '「': '」', '『': '』',
+}
+
+// SplitDialogue separates a paragraph into the text inside quotation marks
+// and the text outside. Straight and smart double quotes, guillemets and CJK
+// brackets always quote. Single quotes only open before a word and close
+// after one so apostrophes (don't, dogs') stay narration. Quotes nested in
+// an open quote are part of it, and a quote still open at the end of the
+// paragraph runs to the end as speech carried on to the next paragraph.
+func SplitDialogue(para string) (string, string) {
+	var dialogue, narration strings.Builder
+	runes := []rune(para)
+	open := rune(0)
+	closer := rune(0)
+
+	for i, r := range runes {
+		prev, next := ' ', ' '
+		if i > 0 {
+			prev = runes[i-1]
+		}
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		single := r == '\'' || r == '‘' || r == '’'
+
+		switch {
+		case open == 0 && quoteClose[r] != 0 && (!single || (!isWordRune(prev) && isWordRune(next))):
+			open = r
+			closer = quoteClose[r]
+			narration.WriteRune(' ')
+			continue
+		case open != 0 && r == closer && (!single || !isWordRune(next)):
+			open = 0
+			dialogue.WriteRune(' ')
+			continue
+		}
+
+		if open != 0 {
+			dialogue.WriteRune(r)
+		} else {
+			narration.WriteRune(r)
+		}
+	}
+
+	return dialogue.String(), narration.String()
+}
+
+// CalcProse works out the dialogue and paragraph shape of a text, one
+// paragraph to a line as the text export gives it
+func CalcProse(text string, mode string) ProseStat {
+	tokenize := GetTokenizer(mode)
+	ps := ProseStat{
+		ParagraphHist: make([]int, ParagraphHistBuckets),
+	}
+
+	total := 0
+	for _, para := range splitParagraphs(text) {
+		dialogue, narration := SplitDialogue(para)
+		dWords := len(tokenize(dialogue))
+		nWords := len(tokenize(narration))
+		words := dWords + nWords
+		if words == 0 {
+			continue
+		}
+
+		ps.DialogueWords += dWords
+		ps.NarrationWords += nWords
+		if dWords > 0 {
+			ps.DialogueParagraphs++
+		}
+
+		ps.Paragraphs++
+		total += words
+		if words > ps.LongestParagraph {
+			ps.LongestParagraph = words
+		}
+		bucket := words / ParagraphHistStep
+		if bucket >= ParagraphHistBuckets {
+			bucket = ParagraphHistBuckets - 1
+		}
+		ps.ParagraphHist[bucket]++
+	}
+
+	if ps.Paragraphs > 0 {
+		ps.AvgParagraph = float64(total) / float64(ps.Paragraphs)
+	}
+
+	return ps
+}
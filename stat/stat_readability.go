@@ -0,0 +1,164 @@
+package stat
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Width in words of each sentence length histogram bucket, the last bucket
+// holds everything longer
+const (
+	SentenceHistStep    = 5
+	SentenceHistBuckets = 10
+)
+
+type Readability struct {
+	Sentences         int     `json:"Sentences"`
+	Paragraphs        int     `json:"Paragraphs"`
+	Syllables         int     `json:"Syllables"`
+	AvgSentenceLength float64 `json:"AvgSentenceLength"`
+	FleschEase        float64 `json:"FleschEase"`
+	FleschKincaid     float64 `json:"FleschKincaid"`
+	SentenceHist      []int   `json:"SentenceHist"`
+}
+
+func (r Readability) String() string {
+	return fmt.Sprintf("%d sentences, %d paragraphs, %.1f words/sentence, ease %.1f, grade %.1f",
+		r.Sentences, r.Paragraphs, r.AvgSentenceLength, r.FleschEase, r.FleschKincaid)
+}
+
+var sentenceAbbrev = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "st": true, "vs": true, "etc": true, "e.g": true, "i.e": true,
+}
+
+func isSentenceEnd(c rune) bool {
+	return c == '.' || c == '!' || c == '?' || c == '…' || c == '。' || c == '！' || c == '？'
+}
+
+// splitParagraphs returns the non blank lines, the text export puts each
+// paragraph on its own line
+func splitParagraphs(s string) []string {
+	paras := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			paras = append(paras, line)
+		}
+	}
+	return paras
+}
+
+// splitSentences breaks a paragraph after end punctuation, skipping common
+// abbreviations
+func splitSentences(para string) []string {
+	sentences := []string{}
+	runes := []rune(para)
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+		if !isSentenceEnd(runes[i]) {
+			continue
+		}
+
+		// Swallow runs of punctuation and closing quotes
+		end := i + 1
+		for end < len(runes) && (isSentenceEnd(runes[end]) || strings.ContainsRune("\"'”’)", runes[end])) {
+			end++
+		}
+
+		if runes[i] == '.' && end < len(runes) && !unicode.IsSpace(runes[end]) {
+			i = end - 1
+			continue
+		}
+
+		if runes[i] == '.' {
+			lastWord := strings.Fields(string(runes[start:i]))
+			if len(lastWord) > 0 && sentenceAbbrev[strings.ToLower(lastWord[len(lastWord)-1])] {
+				i = end - 1
+				continue
+			}
+		}
+
+		if s := strings.TrimSpace(string(runes[start:end])); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = end
+		i = end - 1
+	}
+
+	if s := strings.TrimSpace(string(runes[start:])); hasLetterOrNumber(s) {
+		sentences = append(sentences, s)
+	}
+
+	return sentences
+}
+
+// syllables estimates English syllables by counting vowel groups
+func syllables(w string) int {
+	w = strings.ToLower(w)
+	count := 0
+	prevVowel := false
+	for _, c := range w {
+		v := strings.ContainsRune("aeiouy", c)
+		if v && !prevVowel {
+			count++
+		}
+		prevVowel = v
+	}
+
+	if strings.HasSuffix(w, "e") && !strings.HasSuffix(w, "le") && count > 1 {
+		count--
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// CalcReadability works out sentence and Flesch scores for the text
+func CalcReadability(s string, mode string) Readability {
+	r := Readability{
+		SentenceHist: make([]int, SentenceHistBuckets),
+	}
+
+	tokenize := GetTokenizer(mode)
+	words := 0
+
+	paras := splitParagraphs(s)
+	r.Paragraphs = len(paras)
+
+	for _, p := range paras {
+		for _, sentence := range splitSentences(p) {
+			sWords := tokenize(sentence)
+			if len(sWords) == 0 {
+				continue
+			}
+
+			r.Sentences++
+			words += len(sWords)
+			for _, w := range sWords {
+				r.Syllables += syllables(w)
+			}
+
+			bucket := len(sWords) / SentenceHistStep
+			if bucket >= SentenceHistBuckets {
+				bucket = SentenceHistBuckets - 1
+			}
+			r.SentenceHist[bucket]++
+		}
+	}
+
+	if r.Sentences == 0 || words == 0 {
+		return r
+	}
+
+	wordsPerSentence := float64(words) / float64(r.Sentences)
+	syllablesPerWord := float64(r.Syllables) / float64(words)
+
+	r.AvgSentenceLength = wordsPerSentence
+	r.FleschEase = 206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord
+	r.FleschKincaid = 0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59
+
+	return r
+}
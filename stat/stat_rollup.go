@@ -0,0 +1,193 @@
+package stat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RollupWeek  = "week"
+	RollupMonth = "month"
+	RollupYear  = "year"
+)
+
+var RollupKinds = []string{RollupWeek, RollupMonth, RollupYear}
+
+// RollupDay is what one daily stat adds to a rollup
+type RollupDay struct {
+	WordAdd int      `json:"WordAdd"`
+	WordSub int      `json:"WordSub"`
+	CharAdd int      `json:"CharAdd"`
+	CharSub int      `json:"CharSub"`
+	Files   []string `json:"Files"`
+}
+
+// RollupStat totals the daily stats of an ISO week, month or year
+type RollupStat struct {
+	Kind         string               `json:"Kind"`
+	Period       string               `json:"Period"`
+	WordAdd      int                  `json:"WordAdd"`
+	WordSub      int                  `json:"WordSub"`
+	CharAdd      int                  `json:"CharAdd"`
+	CharSub      int                  `json:"CharSub"`
+	ActiveDays   int                  `json:"ActiveDays"`
+	DocsTouched  int                  `json:"DocsTouched"`
+	BestDay      string               `json:"BestDay"`
+	BestDayWords int                  `json:"BestDayWords"`
+	Days         map[string]RollupDay `json:"Days"`
+}
+
+func (r RollupStat) String() string {
+	return fmt.Sprintf("[%s %s] Words %d / %d over %d days in %d docs, best %s (%d)",
+		r.Kind, r.Period, r.WordAdd, r.WordSub, r.ActiveDays, r.DocsTouched, r.BestDay, r.BestDayWords)
+}
+
+// Key is the database key for the rollup
+func (r RollupStat) Key() string {
+	return RollupKey(r.Kind, r.Period)
+}
+
+func RollupKey(kind string, period string) string {
+	return kind + ":" + period
+}
+
+// PeriodKey names the week (2016-W03), month (2016-01) or year (2016) of a date
+func PeriodKey(kind string, date time.Time) string {
+	switch kind {
+	case RollupWeek:
+		year, week := date.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case RollupMonth:
+		return date.Format("2006-01")
+	case RollupYear:
+		return date.Format("2006")
+	}
+	return ""
+}
+
+// PeriodStart returns the first day of a period key
+func PeriodStart(kind string, period string) (time.Time, error) {
+	switch kind {
+	case RollupWeek:
+		parts := strings.Split(period, "-W")
+		if len(parts) != 2 {
+			return time.Time{}, fmt.Errorf("Bad week %s", period)
+		}
+		year, errYear := strconv.Atoi(parts[0])
+		week, errWeek := strconv.Atoi(parts[1])
+		if errYear != nil || errWeek != nil {
+			return time.Time{}, fmt.Errorf("Bad week %s", period)
+		}
+
+		// Jan 4th is always in week 1
+		jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+		offset := (int(jan4.Weekday()) + 6) % 7
+		return jan4.AddDate(0, 0, -offset+(week-1)*7), nil
+	case RollupMonth:
+		return time.Parse("2006-01", period)
+	case RollupYear:
+		return time.Parse("2006", period)
+	}
+	return time.Time{}, fmt.Errorf("Bad rollup kind %s", kind)
+}
+
+// PeriodEnd returns the first day after a period
+func PeriodEnd(kind string, start time.Time) time.Time {
+	switch kind {
+	case RollupWeek:
+		return start.AddDate(0, 0, 7)
+	case RollupMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(1, 0, 0)
+}
+
+// ShiftPeriod returns the period key n periods away
+func ShiftPeriod(kind string, period string, n int) string {
+	start, err := PeriodStart(kind, period)
+	if err != nil {
+		return ""
+	}
+
+	switch kind {
+	case RollupWeek:
+		return PeriodKey(kind, start.AddDate(0, 0, 7*n))
+	case RollupMonth:
+		return PeriodKey(kind, start.AddDate(0, n, 0))
+	}
+	return PeriodKey(kind, start.AddDate(n, 0, 0))
+}
+
+func NewRollupStat(kind string, period string) *RollupStat {
+	return &RollupStat{
+		Kind:   kind,
+		Period: period,
+		Days:   make(map[string]RollupDay),
+	}
+}
+
+// SetDay replaces the contribution of one day and recalculates the totals
+func (r *RollupStat) SetDay(day *DailyUserStat) {
+	if r.Days == nil {
+		r.Days = make(map[string]RollupDay)
+	}
+
+	files := []string{}
+	for k := range day.FileRevs {
+		files = append(files, k)
+	}
+	sort.Strings(files)
+
+	r.Days[day.ModDate] = RollupDay{
+		WordAdd: day.WordAdd,
+		WordSub: day.WordSub,
+		CharAdd: day.CharAdd,
+		CharSub: day.CharSub,
+		Files:   files,
+	}
+
+	r.recalc()
+}
+
+func (r *RollupStat) recalc() {
+	r.WordAdd = 0
+	r.WordSub = 0
+	r.CharAdd = 0
+	r.CharSub = 0
+	r.ActiveDays = 0
+	r.BestDay = ""
+	r.BestDayWords = 0
+
+	docs := make(map[string]bool)
+	for date, d := range r.Days {
+		r.WordAdd += d.WordAdd
+		r.WordSub += d.WordSub
+		r.CharAdd += d.CharAdd
+		r.CharSub += d.CharSub
+		if d.WordAdd != 0 || d.WordSub != 0 {
+			r.ActiveDays++
+		}
+		for _, f := range d.Files {
+			docs[f] = true
+		}
+
+		if d.WordAdd > r.BestDayWords || (d.WordAdd == r.BestDayWords && d.WordAdd > 0 && date < r.BestDay) {
+			r.BestDay = date
+			r.BestDayWords = d.WordAdd
+		}
+	}
+	r.DocsTouched = len(docs)
+}
+
+// SortedDays lists the dates in the rollup in order
+func (r RollupStat) SortedDays() []string {
+	dates := []string{}
+	for k := range r.Days {
+		dates = append(dates, k)
+	}
+	sort.Strings(dates)
+	return dates
+}
@@ -0,0 +1,98 @@
+package stat
+
+import (
+	"unicode"
+)
+
+// Scripts we report on, anything else is counted as "Other"
+var scriptTables = []struct {
+	Name  string
+	Table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Arabic", unicode.Arabic},
+	{"Hebrew", unicode.Hebrew},
+	{"Thai", unicode.Thai},
+	{"Devanagari", unicode.Devanagari},
+}
+
+// isCJK is true for scripts written without spaces, where Google Docs counts
+// every character as a word. Hangul uses spaces so is counted as words.
+func isCJK(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// runeScript names the script of a character
+func runeScript(c rune) string {
+	for _, s := range scriptTables {
+		if unicode.Is(s.Table, c) {
+			return s.Name
+		}
+	}
+	return "Other"
+}
+
+// wordScript names the script of the first letter in a word, "" if none
+func wordScript(w string) string {
+	for _, c := range w {
+		if unicode.IsLetter(c) {
+			return runeScript(c)
+		}
+	}
+	return ""
+}
+
+// splitCJK breaks each CJK character out into its own word. Pieces left
+// between the characters are dropped if they are only punctuation (。「」).
+func splitCJK(words []string) []string {
+	result := make([]string, 0, len(words))
+
+	for _, w := range words {
+		start := 0
+		split := false
+		for i, c := range w {
+			if !isCJK(c) {
+				continue
+			}
+			if start < i && hasLetterOrNumber(w[start:i]) {
+				result = append(result, w[start:i])
+			}
+			result = append(result, string(c))
+			start = i + len(string(c))
+			split = true
+		}
+		if start < len(w) && (!split || hasLetterOrNumber(w[start:])) {
+			result = append(result, w[start:])
+		}
+	}
+
+	return result
+}
+
+// ScriptCounts counts words by the script they are written in
+func ScriptCounts(words []string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range words {
+		if s := wordScript(w); s != "" {
+			counts[s]++
+		}
+	}
+	return counts
+}
+
+// DominantScript is the script with the most words
+func DominantScript(counts map[string]int) string {
+	best := ""
+	for k, v := range counts {
+		if best == "" || v > counts[best] || (v == counts[best] && k < best) {
+			best = k
+		}
+	}
+	return best
+}
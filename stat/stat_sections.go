@@ -0,0 +1,230 @@
+package stat
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// TextBlock is a heading or paragraph pulled from the HTML export
+type TextBlock struct {
+	Level int // 1-6 for headings, 0 for body text
+	Text  string
+}
+
+type SectionStat struct {
+	Title      string `json:"Title"`
+	Level      int    `json:"Level"`
+	Path       string `json:"Path"`
+	Words      int    `json:"Words"`
+	TotalWords int    `json:"TotalWords"`
+
+	// Words of the section's own text inside quotation marks
+	DialogueWords int `json:"DialogueWords"`
+}
+
+type SectionChange struct {
+	Path   string `json:"Path"`
+	Before int    `json:"Before"`
+	After  int    `json:"After"`
+}
+
+type SectionDay struct {
+	Date    string          `json:"Date"`
+	Changes []SectionChange `json:"Changes"`
+}
+
+func (sec SectionStat) String() string {
+	return fmt.Sprintf("%s%s: %d (%d)", strings.Repeat("  ", sec.Level), sec.Title, sec.Words, sec.TotalWords)
+}
+
+func (sc SectionChange) Diff() int {
+	return sc.After - sc.Before
+}
+
+// Tags that end a block of text
+var blockTags = map[string]bool{
+	"p": true, "div": true, "li": true, "br": true, "tr": true, "td": true, "table": true,
+	"ul": true, "ol": true, "body": true, "title": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Tags whose content is never text
+var skipTags = map[string]bool{
+	"head": true, "style": true, "script": true,
+}
+
+// ParseHTMLBlocks splits an HTML export into headings and paragraphs. It is a
+// simple scanner, enough for the well formed HTML Google Docs exports.
+func ParseHTMLBlocks(s string) []TextBlock {
+	blocks := []TextBlock{}
+	text := ""
+	level := 0
+	skip := ""
+
+	flush := func() {
+		t := strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+		if t != "" {
+			blocks = append(blocks, TextBlock{Level: level, Text: t})
+		}
+		text = ""
+	}
+
+	for len(s) > 0 {
+		open := strings.IndexByte(s, '<')
+		if open < 0 {
+			if skip == "" {
+				text += s
+			}
+			break
+		}
+
+		if skip == "" {
+			text += s[:open]
+		}
+
+		close := strings.IndexByte(s[open:], '>')
+		if close < 0 {
+			break
+		}
+		tag := s[open+1 : open+close]
+		s = s[open+close+1:]
+
+		closing := strings.HasPrefix(tag, "/")
+		fields := strings.Fields(strings.Trim(tag, "/ "))
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+
+		if skip != "" {
+			if closing && name == skip {
+				skip = ""
+			}
+			continue
+		}
+		if !closing && skipTags[name] {
+			skip = name
+			continue
+		}
+
+		if !blockTags[name] {
+			continue
+		}
+
+		flush()
+		if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+			if closing {
+				level = 0
+			} else {
+				level = int(name[1] - '0')
+			}
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// CalcSections builds the heading tree, in document order, with the word
+// count under each heading. Text before the first heading is its own section.
+func CalcSections(blocks []TextBlock, mode string) []SectionStat {
+	tokenize := GetTokenizer(mode)
+	sections := []SectionStat{}
+	stack := []int{}
+
+	for _, b := range blocks {
+		if b.Level == 0 {
+			if len(sections) == 0 {
+				sections = append(sections, SectionStat{Title: "(start)", Path: "(start)"})
+				stack = []int{0}
+			}
+			dialogue, narration := SplitDialogue(b.Text)
+			spoken := len(tokenize(dialogue))
+			words := spoken + len(tokenize(narration))
+			sections[len(sections)-1].Words += words
+			sections[len(sections)-1].DialogueWords += spoken
+			for _, i := range stack {
+				sections[i].TotalWords += words
+			}
+			continue
+		}
+
+		// The opening text is never a parent
+		for len(stack) > 0 && (sections[stack[len(stack)-1]].Level >= b.Level || sections[stack[len(stack)-1]].Level == 0) {
+			stack = stack[:len(stack)-1]
+		}
+
+		path := b.Text
+		if len(stack) > 0 {
+			path = sections[stack[len(stack)-1]].Path + " / " + b.Text
+		}
+
+		sections = append(sections, SectionStat{Title: b.Text, Level: b.Level, Path: path})
+		stack = append(stack, len(sections)-1)
+	}
+
+	return sections
+}
+
+// DocSectionChanges compares the sections at the end of each day with the
+// day before
+func DocSectionChanges(doc *DocStat) []SectionDay {
+	days := []SectionDay{}
+	prev := []SectionStat{}
+	prevKeys := []string{}
+	prevWords := map[string]int{}
+	prevDate := ""
+	var last *RevStat
+
+	finishDay := func() {
+		if last == nil || last.Sections == nil {
+			return
+		}
+
+		day := SectionDay{Date: prevDate}
+		keys := sectionKeys(last.Sections)
+		current := map[string]int{}
+		for i, sec := range last.Sections {
+			current[keys[i]] = sec.Words
+			if before, ok := prevWords[keys[i]]; !ok || before != sec.Words {
+				day.Changes = append(day.Changes, SectionChange{Path: sec.Path, Before: before, After: sec.Words})
+			}
+		}
+		for i, sec := range prev {
+			if _, ok := current[prevKeys[i]]; !ok {
+				day.Changes = append(day.Changes, SectionChange{Path: sec.Path, Before: sec.Words, After: 0})
+			}
+		}
+
+		if len(day.Changes) > 0 {
+			days = append(days, day)
+		}
+		prev, prevKeys, prevWords = last.Sections, keys, current
+	}
+
+	for i := range doc.RevList {
+		rev := &doc.RevList[i]
+		shortDate := rev.ModDate[:10]
+		if shortDate != prevDate {
+			finishDay()
+		}
+		prevDate = shortDate
+		last = rev
+	}
+	finishDay()
+
+	return days
+}
+
+// sectionKeys tells apart sections with the same path by how many of them
+// come before
+func sectionKeys(sections []SectionStat) []string {
+	seen := map[string]int{}
+	keys := make([]string, len(sections))
+	for i, sec := range sections {
+		keys[i] = fmt.Sprintf("%s\x00%d", sec.Path, seen[sec.Path])
+		seen[sec.Path]++
+	}
+	return keys
+}
@@ -0,0 +1,72 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+)
+
+// Settings are the user choices for how stats are worked out
+type Settings struct {
+	TokenMode    string   `json:"TokenMode"`
+	StopLanguage string   `json:"StopLanguage"`
+	StopWords    []string `json:"StopWords"`
+	Stemming     bool     `json:"Stemming"`
+
+	// Phrases used more than this many times per 1000 words are overused
+	PhraseRate float64 `json:"PhraseRate"`
+
+	// Also fetch the HTML export of each revision to count words per heading
+	FetchSections bool `json:"FetchSections"`
+
+	// Time zone name for time of day stats, blank for the local zone
+	TimeZone string `json:"TimeZone"`
+
+	// Revisions adding PasteWords faster than PasteWPM are treated as imported
+	PasteWords int `json:"PasteWords"`
+	PasteWPM   int `json:"PasteWPM"`
+
+	// Used for manuscript page and reading time estimates
+	WordsPerPage int `json:"WordsPerPage"`
+	ReadingWPM   int `json:"ReadingWPM"`
+
+	// Crutch words to count, -ly counts words ending in ly
+	FillerWords []string `json:"FillerWords"`
+	// A content word used again within this many words is an echo, 0 is off
+	EchoWindow int `json:"EchoWindow"`
+
+	// Deleted passages of this many words or more are kept, 0 is off
+	CutWords int `json:"CutWords"`
+}
+
+func DefaultSettings() *Settings {
+	return &Settings{
+		TokenMode:    TokenModeDefault,
+		StopLanguage: "en",
+		StopWords:    []string{},
+		Stemming:     true,
+		PhraseRate:   1.0,
+		PasteWords:   500,
+		PasteWPM:     200,
+		WordsPerPage: DefaultWordsPerPage,
+		ReadingWPM:   DefaultReadingWPM,
+		FillerWords:  append([]string{}, DefaultFillerWords...),
+		EchoWindow:   DefaultEchoWindow,
+		CutWords:     DefaultCutWords,
+	}
+}
+
+// Location returns the time zone for time of day stats
+func (set *Settings) Location() *time.Location {
+	if set.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(set.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+func (set *Settings) String() string {
+	return fmt.Sprintf("Tokenizer: %s Stop words: %s +%d Stemming: %v", set.TokenMode, set.StopLanguage, len(set.StopWords), set.Stemming)
+}
@@ -0,0 +1,73 @@
+package stat
+
+import (
+	"strings"
+)
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+func hasVowel(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if isVowel(s[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// measure counts the vowel-consonant sequences in a word, as in Porter's m
+func measure(s string) int {
+	m := 0
+	prevVowel := false
+	for i := 0; i < len(s); i++ {
+		v := isVowel(s[i])
+		if prevVowel && !v {
+			m++
+		}
+		prevVowel = v
+	}
+	return m
+}
+
+// Stem is a light English suffix stripper, enough to group run/runs/running
+// together. It is not a full Porter stemmer.
+func Stem(w string) string {
+	if len(w) <= 3 {
+		return w
+	}
+
+	switch {
+	case strings.HasSuffix(w, "sses"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "ies"):
+		w = w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"), strings.HasSuffix(w, "is"):
+	case strings.HasSuffix(w, "'s"):
+		w = w[:len(w)-2]
+	case strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed", "ly"} {
+		stem := strings.TrimSuffix(w, suffix)
+		if stem == w || len(stem) < 3 || !hasVowel(stem) || (suffix == "ed" && stem[len(stem)-1] == 'e') {
+			continue
+		}
+
+		n := len(stem)
+		if stem[n-1] == stem[n-2] && !isVowel(stem[n-1]) && strings.IndexByte("lsz", stem[n-1]) < 0 {
+			// running -> run
+			stem = stem[:n-1]
+		} else if suffix != "ly" && measure(stem) == 1 && !isVowel(stem[n-1]) && isVowel(stem[n-2]) && !isVowel(stem[n-3]) && strings.IndexByte("wxy", stem[n-1]) < 0 {
+			// hoping -> hope
+			stem = stem + "e"
+		}
+
+		w = stem
+		break
+	}
+
+	return w
+}
@@ -0,0 +1,95 @@
+package stat
+
+import (
+	"sort"
+	"strings"
+)
+
+// Built in stop lists, the most common function words of each language
+var stopLists = map[string]string{
+	"en": `a about above after again against all am an and any are as at be because been before
+		being below between both but by can could did do does doing down during each few for from
+		further had has have having he her here hers herself him himself his how i if in into is it
+		its itself just me more most my myself no nor not now of off on once only or other our ours
+		ourselves out over own same she should so some such than that the their theirs them
+		themselves then there these they this those through to too under until up very was we were
+		what when where which while who whom why will with would you your yours yourself yourselves
+		it's i'm i've i'd i'll don't didn't can't won't isn't wasn't he's she's that's there's
+		they're we're you're let's`,
+	"fr": `au aux avec ce ces dans de des du elle en et eux il ils je la le les leur lui ma mais me
+		même mes moi mon ne nos notre nous on ou par pas pour qu que qui sa se ses son sur ta te tes
+		toi ton tu un une vos votre vous c d j l à m n s t y été était est sont être avoir a ont`,
+	"de": `aber alle als also am an auch auf aus bei bin bis bist da damit dann das dass dein dem
+		den der des dich die dir du ein eine einem einen einer es für hat hatte ich ihr im in ist
+		ja kann kein man mein mich mir mit nach nicht noch nur oder sein sich sie sind so über um
+		und uns unter vom von vor war was weil wenn wie wir wird zu zum zur`,
+	"es": `a al algo como con de del donde el ella ellas ellos en era es esta este esto fue ha hay
+		la las le les lo los me mi mis muy más no nos o para pero por que se si sin sobre su sus te
+		tu un una uno y ya yo él`,
+}
+
+// StopLanguages lists the languages with a built in stop list
+func StopLanguages() []string {
+	langs := []string{}
+	for k := range stopLists {
+		langs = append(langs, k)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// IsStopLanguage reports if lang has a built in stop list, "" is no list
+func IsStopLanguage(lang string) bool {
+	_, ok := stopLists[lang]
+	return ok || lang == ""
+}
+
+// StopWordSet builds the stop list for a language plus any user additions
+func StopWordSet(lang string, extra []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(stopLists[lang]) {
+		set[w] = true
+	}
+	for _, w := range extra {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w != "" {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// contentWordMap removes stop words and, if stemming, merges words sharing a
+// stem under the most used spelling
+func contentWordMap(wordMap map[string]int, stop map[string]bool, stemming bool) map[string]int {
+	result := make(map[string]int)
+
+	if !stemming {
+		for k, v := range wordMap {
+			if k != "" && !stop[k] {
+				result[k] = v
+			}
+		}
+		return result
+	}
+
+	stemCount := make(map[string]int)
+	stemWord := make(map[string]string)
+	for k, v := range wordMap {
+		if k == "" || stop[k] {
+			continue
+		}
+
+		s := Stem(k)
+		stemCount[s] += v
+		if best, ok := stemWord[s]; !ok || wordMap[best] < v || (wordMap[best] == v && k < best) {
+			stemWord[s] = k
+		}
+	}
+
+	for s, v := range stemCount {
+		result[stemWord[s]] = v
+	}
+
+	return result
+}
@@ -0,0 +1,125 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	shortDateFormat = "2006-01-02"
+
+	// Number of days used to work out the trailing writing pace
+	TargetPaceDays = 14
+)
+
+// Target is a word goal with a deadline, keyed by a file (or project) id
+type Target struct {
+	Id         string `json:"Id"`
+	WordTarget int    `json:"WordTarget"`
+	Deadline   string `json:"Deadline"`
+}
+
+// WordPoint is the word count at the end of a day
+type WordPoint struct {
+	Date  string `json:"Date"`
+	Words int    `json:"Words"`
+}
+
+type TargetProjection struct {
+	Target         Target
+	StartWords     int
+	CurrentWords   int
+	WordsLeft      int
+	DaysLeft       int
+	RequiredPerDay float64
+	TrailingPace   float64
+	ProjectedDate  string
+	OnTrack        bool
+	Points         []WordPoint
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("[%s] %d words by %s", t.Id, t.WordTarget, t.Deadline)
+}
+
+func (tp TargetProjection) String() string {
+	return fmt.Sprintf("%s: %d/%d words, need %.0f/day, pace %.0f/day, finish %s",
+		tp.Target, tp.CurrentWords, tp.Target.WordTarget, tp.RequiredPerDay, tp.TrailingPace, tp.ProjectedDate)
+}
+
+// DocWordPoints returns the last word count of each day with a revision
+func DocWordPoints(doc *DocStat) (points []WordPoint) {
+	for _, v := range doc.RevList {
+		shortDate := v.ModDate[:10]
+
+		if len(points) > 0 && points[len(points)-1].Date == shortDate {
+			points[len(points)-1].Words = v.WordCount
+		} else {
+			points = append(points, WordPoint{Date: shortDate, Words: v.WordCount})
+		}
+	}
+
+	return points
+}
+
+// wordsAt returns the word count standing on a date
+func wordsAt(points []WordPoint, date time.Time) int {
+	words := 0
+	shortDate := date.Format(shortDateFormat)
+	for _, p := range points {
+		if p.Date > shortDate {
+			break
+		}
+		words = p.Words
+	}
+	return words
+}
+
+// ProjectTarget works out the pace needed to hit the target and when the
+// current pace will get there
+func ProjectTarget(target Target, points []WordPoint, now time.Time) TargetProjection {
+	tp := TargetProjection{
+		Target: target,
+		Points: points,
+	}
+
+	if len(points) > 0 {
+		tp.StartWords = points[0].Words
+		tp.CurrentWords = points[len(points)-1].Words
+	}
+
+	tp.WordsLeft = target.WordTarget - tp.CurrentWords
+	if tp.WordsLeft < 0 {
+		tp.WordsLeft = 0
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	deadline, dErr := time.Parse(shortDateFormat, target.Deadline)
+	if dErr == nil {
+		tp.DaysLeft = int(deadline.Sub(today).Hours() / 24)
+	}
+
+	if tp.WordsLeft > 0 {
+		if tp.DaysLeft > 0 {
+			tp.RequiredPerDay = float64(tp.WordsLeft) / float64(tp.DaysLeft)
+		} else {
+			tp.RequiredPerDay = float64(tp.WordsLeft)
+		}
+	}
+
+	paceStart := today.AddDate(0, 0, -TargetPaceDays)
+	tp.TrailingPace = float64(tp.CurrentWords-wordsAt(points, paceStart)) / TargetPaceDays
+
+	if tp.WordsLeft == 0 {
+		tp.ProjectedDate = today.Format(shortDateFormat)
+	} else if tp.TrailingPace > 0 {
+		daysNeeded := int(math.Ceil(float64(tp.WordsLeft) / tp.TrailingPace))
+		tp.ProjectedDate = today.AddDate(0, 0, daysNeeded).Format(shortDateFormat)
+	}
+
+	tp.OnTrack = tp.ProjectedDate != "" && (dErr != nil || tp.ProjectedDate <= target.Deadline)
+
+	return tp
+}
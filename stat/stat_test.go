@@ -1,6 +1,11 @@
 package stat
 
-import "testing"
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestWordCount(t *testing.T) {
 
@@ -41,3 +46,980 @@ And having thee, of all men's pride I boast:
 	}
 
 }
+
+func TestWordCountCJK(t *testing.T) {
+
+	td := []struct {
+		text        string
+		wordCount   int
+		uniqueWords int
+		script      string
+	}{{`我爱你。你爱我吗？`, 7, 4, "Han"},
+		{`今日はいい天気ですね`, 10, 9, "Hiragana"},
+		{`I wrote 東京タワー today`, 8, 8, "Latin"},
+		{`한국어 문장은 띄어쓰기를 합니다`, 4, 4, "Hangul"},
+		{`Vulkan 渲染器 renderer в полёте`, 7, 7, "Han"}}
+
+	for i, v := range td {
+
+		m, wc := wordCount(v.text)
+
+		if wc != v.wordCount {
+			t.Errorf("[%d] Word Count failed %d != %d", i, wc, v.wordCount)
+		}
+		if len(m) != v.uniqueWords {
+			t.Errorf("[%d] Unique Count failed %d != %d", i, len(m), v.uniqueWords)
+		}
+
+		scripts := ScriptCounts(GetTokenizer(TokenModeDefault)(v.text))
+		if s := DominantScript(scripts); s != v.script {
+			t.Errorf("[%d] Script failed %s != %s %v", i, s, v.script, scripts)
+		}
+	}
+
+}
+
+func TestReadability(t *testing.T) {
+	text := "The cat sat on the mat. Mr. Smith saw it!\r\n\r\nDid he care? No.\r\nVersion 1.5 shipped today"
+
+	r := CalcReadability(text, TokenModeDefault)
+
+	if r.Paragraphs != 3 {
+		t.Errorf("Paragraphs %d != 3", r.Paragraphs)
+	}
+	if r.Sentences != 5 {
+		t.Errorf("Sentences %d != 5", r.Sentences)
+	}
+	if r.SentenceHist[0] != 3 || r.SentenceHist[1] != 2 {
+		t.Errorf("Histogram wrong %v", r.SentenceHist)
+	}
+
+	simple := CalcReadability("The cat sat on the mat. The dog ran to the cat.", TokenModeDefault)
+	complex := CalcReadability("Unquestionably, organisational communication necessitates comprehensive documentation and considerable deliberation.", TokenModeDefault)
+	if simple.FleschEase <= complex.FleschEase || simple.FleschKincaid >= complex.FleschKincaid {
+		t.Errorf("Simple text should read easier %s / %s", simple, complex)
+	}
+
+	for w, n := range map[string]int{"cat": 1, "table": 2, "make": 1, "reading": 2, "documentation": 5} {
+		if s := syllables(w); s != n {
+			t.Errorf("Syllables %s %d != %d", w, s, n)
+		}
+	}
+}
+
+func TestPhrases(t *testing.T) {
+	set := DefaultSettings()
+	stop := StopWordSet(set.StopLanguage, nil)
+
+	text := "At the end of the day we won. At the end of the day we lost.\nOf the many, at the end."
+	bi := ngramCounts(text, 2, set.TokenMode, stop)
+	if bi["the end"] != 3 || bi["of the"] != 0 || bi["won at"] != 0 {
+		t.Errorf("Bigrams wrong %v", bi)
+	}
+
+	tri := ngramCounts(text, 3, set.TokenMode, stop)
+	if tri["at the end"] != 3 || tri["end of the"] != 2 {
+		t.Errorf("Trigrams wrong %v", tri)
+	}
+
+	doc := &DocStat{}
+	texts := map[string]string{"1": text, "2": text + " " + text, "3": "At the end we won."}
+	for _, id := range []string{"1", "2", "3"} {
+		s := texts[id]
+		rev := RevStat{RevId: id}
+		rev.WordCount = len(GetTokenizer(set.TokenMode)(s))
+		CalcPhrases(&rev, s, set)
+		doc.RevList = append(doc.RevList, rev)
+	}
+
+	if len(doc.RevList[0].Overused) != 2 {
+		t.Errorf("Overused wrong %v", doc.RevList[0].Overused)
+	}
+
+	trends := DocPhraseTrends(doc)
+	if len(trends) != 9 {
+		t.Fatalf("Trends wrong %v", trends)
+	}
+	for _, pt := range trends {
+		if !pt.Reduced || pt.Latest != 0 {
+			t.Errorf("Phrase should be reduced %s", pt)
+		}
+	}
+	if trends[0].Phrase != "at the end" || trends[0].Peak != 6 {
+		t.Errorf("Trend order wrong %v", trends)
+	}
+
+	// The last revision still uses the phrase once, under the flag rate
+	TrackCounts(doc, func(fileId string, revId string) (string, bool) {
+		t, ok := texts[revId]
+		return t, ok
+	}, set)
+	trends = DocPhraseTrends(doc)
+	if trends[0].Phrase != "at the end" || trends[0].Latest != 1 || trends[0].Counts[0] != 3 || !trends[0].Reduced {
+		t.Errorf("Tracked trend wrong %v", trends[0])
+	}
+}
+
+func TestVocab(t *testing.T) {
+	v := CalcVocab("The cat and the dog and the bird", TokenModeDefault)
+	if v.Tokens != 8 || v.Types != 5 || v.Hapax != 3 {
+		t.Errorf("Vocab counts wrong %s", v)
+	}
+	if v.TTR != 5.0/8.0 || v.MATTR != v.TTR {
+		t.Errorf("Vocab ratios wrong %s", v)
+	}
+
+	words := []string{"a", "b", "a", "c", "c"}
+	if m := movingTTR(words, 2); m != (1.0+1.0+1.0+0.5)/4 {
+		t.Errorf("Moving TTR wrong %f", m)
+	}
+
+	doc := &DocStat{RevList: []RevStat{
+		{ModDate: "2016-01-01T10:00:00.000Z", Vocab: VocabStat{Types: 1}},
+		{ModDate: "2016-01-01T11:00:00.000Z", Vocab: VocabStat{Types: 2}},
+		{ModDate: "2016-01-02T11:00:00.000Z", Vocab: VocabStat{Types: 3}},
+		{ModDate: "2016-02-02T11:00:00.000Z", Vocab: VocabStat{Types: 4}},
+	}}
+	days := DocVocabByPeriod(doc, 10)
+	months := DocVocabByPeriod(doc, 7)
+	if len(days) != 3 || days[0].Vocab.Types != 2 || len(months) != 2 || months[0].Vocab.Types != 3 {
+		t.Errorf("Vocab periods wrong %v %v", days, months)
+	}
+}
+
+func TestSections(t *testing.T) {
+	page := `<html><head><style>p { color: red }</style><title>Doc</title></head><body>
+<p class="c1"><span>Opening words here.</span></p>
+<h1 id="h.1"><span>Part One</span></h1>
+<p><span>Some </span><span>text&nbsp;in part one.</span></p>
+<h2><span>Chapter 1</span></h2><p>One two three.</p><p>Four five.</p>
+<h2><span>Chapter 2</span></h2><p>Six &amp; seven.</p>
+<h1>Part Two</h1><p>Eight</p>
+</body></html>`
+
+	blocks := ParseHTMLBlocks(page)
+	if len(blocks) != 10 || blocks[0].Text != "Opening words here." || blocks[1].Level != 1 || blocks[2].Text != "Some text in part one." {
+		t.Fatalf("Blocks wrong %v", blocks)
+	}
+
+	if b := ParseHTMLBlocks("<p>One <> two</ ><br/ >three</p>"); len(b) != 2 || b[0].Text != "One two" {
+		t.Errorf("Empty tags %v", b)
+	}
+
+	sections := CalcSections(blocks, TokenModeDefault)
+	expect := []SectionStat{
+		{"(start)", 0, "(start)", 3, 3, 0},
+		{"Part One", 1, "Part One", 5, 12, 0},
+		{"Chapter 1", 2, "Part One / Chapter 1", 5, 5, 0},
+		{"Chapter 2", 2, "Part One / Chapter 2", 2, 2, 0},
+		{"Part Two", 1, "Part Two", 1, 1, 0},
+	}
+	if len(sections) != len(expect) {
+		t.Fatalf("Sections wrong %v", sections)
+	}
+	for i, v := range expect {
+		if sections[i] != v {
+			t.Errorf("[%d] Section %v != %v", i, sections[i], v)
+		}
+	}
+
+	doc := &DocStat{RevList: []RevStat{
+		{ModDate: "2016-01-01T10:00:00.000Z", Sections: sections[:2]},
+		{ModDate: "2016-01-02T10:00:00.000Z", Sections: sections[:3]},
+		{ModDate: "2016-01-03T10:00:00.000Z", Sections: sections[:3]},
+		{ModDate: "2016-01-04T10:00:00.000Z", Sections: sections[1:3]},
+	}}
+	days := DocSectionChanges(doc)
+	if len(days) != 3 || len(days[0].Changes) != 2 || len(days[1].Changes) != 1 || days[2].Changes[0].Diff() != -3 {
+		t.Errorf("Section changes wrong %v", days)
+	}
+
+	scenes := []SectionStat{
+		{Title: "Scene", Level: 2, Path: "Chapter 1 / Scene", Words: 10},
+		{Title: "Scene", Level: 2, Path: "Chapter 1 / Scene", Words: 20},
+		{Title: "Alpha", Level: 1, Path: "Alpha", Words: 5},
+		{Title: "Beta", Level: 1, Path: "Beta", Words: 6},
+	}
+	doc = &DocStat{RevList: []RevStat{
+		{ModDate: "2016-01-01T10:00:00.000Z", Sections: scenes},
+		{ModDate: "2016-01-02T10:00:00.000Z", Sections: scenes[:1]},
+	}}
+	days = DocSectionChanges(doc)
+	removed := []SectionChange{{"Chapter 1 / Scene", 20, 0}, {"Alpha", 5, 0}, {"Beta", 6, 0}}
+	if len(days) != 2 || len(days[0].Changes) != 4 || len(days[1].Changes) != len(removed) {
+		t.Fatalf("Same heading changes wrong %v", days)
+	}
+	for i, v := range removed {
+		if days[1].Changes[i] != v {
+			t.Errorf("[%d] Removed %v != %v", i, days[1].Changes[i], v)
+		}
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	docs := []*DocStat{
+		{RevList: []RevStat{
+			{ModDate: "2016-01-04T23:30:00.000Z", WordCount: 100},
+			{ModDate: "2016-01-05T09:15:00.000Z", WordCount: 50},
+			{ModDate: "2016-01-05T09:45:00.000Z", WordCount: 250},
+		}},
+		{RevList: []RevStat{
+			{ModDate: "2016-01-10T12:00:00.000Z", WordCount: 40},
+		}},
+	}
+
+	from := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2016, 1, 9, 0, 0, 0, 0, time.UTC)
+
+	hm := CalcHeatmap(docs, from, to, time.UTC)
+	if hm.Words[time.Monday][23] != 100 || hm.Words[time.Tuesday][9] != 200 || hm.Total != 300 || hm.Max != 200 {
+		t.Errorf("UTC heatmap wrong %s", hm)
+	}
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+	hm = CalcHeatmap(docs, from, to, tokyo)
+	if hm.Words[time.Tuesday][8] != 100 || hm.Words[time.Tuesday][18] != 200 {
+		t.Errorf("Tokyo heatmap wrong %s", hm)
+	}
+}
+
+func TestRollup(t *testing.T) {
+	date := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+	if k := PeriodKey(RollupWeek, date); k != "2015-W53" {
+		t.Errorf("Week key %s", k)
+	}
+	if k := PeriodKey(RollupMonth, date); k != "2016-01" {
+		t.Errorf("Month key %s", k)
+	}
+
+	start, err := PeriodStart(RollupWeek, "2016-W01")
+	if err != nil || start.Format(shortDateFormat) != "2016-01-04" {
+		t.Errorf("Week start %s %v", start, err)
+	}
+	if p := ShiftPeriod(RollupWeek, "2016-W01", -1); p != "2015-W53" {
+		t.Errorf("Previous week %s", p)
+	}
+	if p := ShiftPeriod(RollupMonth, "2016-12", 1); p != "2017-01" {
+		t.Errorf("Next month %s", p)
+	}
+
+	r := NewRollupStat(RollupMonth, "2016-01")
+	r.SetDay(&DailyUserStat{ModDate: "2016-01-02", WordAdd: 100, WordSub: -10, FileRevs: map[string][]string{"a": {"1"}}})
+	r.SetDay(&DailyUserStat{ModDate: "2016-01-05", WordAdd: 300, FileRevs: map[string][]string{"a": {"2"}, "b": {"1"}}})
+	r.SetDay(&DailyUserStat{ModDate: "2016-01-02", WordAdd: 50, WordSub: -20, FileRevs: map[string][]string{"a": {"1"}}})
+
+	if r.WordAdd != 350 || r.WordSub != -20 || r.ActiveDays != 2 || r.DocsTouched != 2 || r.BestDay != "2016-01-05" {
+		t.Errorf("Rollup wrong %s", r)
+	}
+}
+
+func TestProject(t *testing.T) {
+	p := &Project{Id: "p1", Name: "Book", FolderId: "folder"}
+	p.AddFile("a")
+	p.AddFile("a")
+	if len(p.FileIds) != 1 || !p.IsMember("a", nil) || !p.IsMember("b", []string{"folder"}) || p.IsMember("c", []string{"other"}) {
+		t.Errorf("Membership wrong %s", p)
+	}
+	p.RemoveFile("a")
+	if p.IsMember("a", nil) {
+		t.Errorf("Remove failed %s", p)
+	}
+
+	docA := &DocStat{FileId: "a", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", WordCount: 100, WordFreq: []WordPair{{"cat", 5, 0}}},
+		{RevId: "2", ModDate: "2016-01-03T10:00:00.000Z", WordCount: 80, WordFreq: []WordPair{{"cat", 4, 0}}},
+	}}
+	docB := &DocStat{FileId: "b", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-02T10:00:00.000Z", WordCount: 50, WordFreq: []WordPair{{"cat", 2, 0}, {"dog", 3, 0}}},
+	}}
+
+	points := SumWordPoints([][]WordPoint{DocWordPoints(docA), DocWordPoints(docB)})
+	if len(points) != 3 || points[1].Words != 150 || points[2].Words != 130 {
+		t.Errorf("Summed points wrong %v", points)
+	}
+
+	ps := CalcProjectStat(p, []*DocStat{docA, docB}, nil)
+	if ps.WordCount != 130 || ps.WordAdd != 150 || ps.WordSub != -20 || len(ps.Days) != 3 || ps.TopWords[0].Word != "cat" {
+		t.Errorf("Project stat wrong %+v", ps)
+	}
+
+	// A word outside each doc's top ten can still lead the project
+	set := DefaultSettings()
+	terms := map[string]*DocTerms{
+		"a": CalcDocTerms("a", "A", strings.Repeat("alpha bravo charlie delta echo foxtrot golf hotel india juliet ", 4)+"kilo kilo kilo", set),
+		"b": CalcDocTerms("b", "B", strings.Repeat("lima mike november oscar papa quebec romeo sierra tango uniform ", 4)+"kilo kilo kilo", set),
+	}
+	if ps := CalcProjectStat(p, []*DocStat{docA, docB}, terms); ps.TopWords[0].Word != "kilo" || ps.TopWords[0].Count != 6 {
+		t.Errorf("Project top words wrong %v", ps.TopWords)
+	}
+
+	day := &DailyUserStat{ModDate: "2016-01-03", FileWords: map[string]FileDay{"a": {WordSub: -20}, "b": {}}}
+	split := DayByProject(day, map[string][]*Project{"a": {p}})
+	if len(split) != 2 || split[0].Name != "(no project)" || split[1].WordSub != -20 {
+		t.Errorf("Day by project wrong %+v", split)
+	}
+}
+
+func TestFlagImports(t *testing.T) {
+	set := DefaultSettings()
+
+	other := &DocStat{FileId: "old", Title: "Old Draft", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", WordCount: 800, TextHash: TextHash("old draft text")},
+	}}
+	doc := &DocStat{FileId: "new", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-02T10:00:00.000Z", WordCount: 100},
+		{RevId: "2", ModDate: "2016-01-02T10:00:30.000Z", WordCount: 10100},
+		{RevId: "3", ModDate: "2016-01-02T14:00:00.000Z", WordCount: 11000},
+		{RevId: "4", ModDate: "2016-01-03T10:00:00.000Z", WordCount: 11800, TextHash: TextHash("old  draft\ntext")},
+		{RevId: "5", ModDate: "2016-01-03T11:00:00.000Z", WordCount: 11900},
+	}}
+
+	FlagImports(doc, OtherHashes(DocTextHashes([]*DocStat{other, doc}), doc.FileId), set)
+
+	expect := []bool{false, true, false, true, false}
+	for i, v := range doc.RevList {
+		if v.IsImported() != expect[i] {
+			t.Errorf("[%d] Imported %v != %v (%s)", i, v.IsImported(), expect[i], v.ImportReason)
+		}
+	}
+
+	FlagImports(other, OtherHashes(DocTextHashes([]*DocStat{other, doc}), other.FileId), set)
+	if other.RevList[0].ImportReason != "800 words in first revision" {
+		t.Errorf("Original flagged as copy of later doc: %s", other.RevList[0].ImportReason)
+	}
+
+	dates := CreateDailyUserStat([]*DocStat{doc})
+	if dates["2016-01-02"].WordAdd != 1000 || dates["2016-01-02"].WordImported != 10000 || dates["2016-01-03"].WordAdd != 100 {
+		t.Errorf("Imported words not excluded %v", dates)
+	}
+
+	doc.RevList[1].ImportOverride = ImportOverrideCounted
+	dates = CreateDailyUserStat([]*DocStat{doc})
+	if dates["2016-01-02"].WordAdd != 11000 || dates["2016-01-02"].WordImported != 0 {
+		t.Errorf("Override ignored %v", dates)
+	}
+}
+
+func TestUpdateDailyUserStat(t *testing.T) {
+	docA := &DocStat{FileId: "a", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", WordCount: 100},
+		{RevId: "2", ModDate: "2016-01-02T10:00:00.000Z", WordCount: 150},
+	}}
+	docB := &DocStat{FileId: "b", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-02T11:00:00.000Z", WordCount: 40},
+	}}
+
+	stored := CreateDailyUserStat([]*DocStat{docA, docB})
+	load := func(shortDate string) *DailyUserStat {
+		if d, ok := stored[shortDate]; ok {
+			return &d
+		}
+		return nil
+	}
+
+	// Doc a moves its second revision to the third and loses words
+	newA := &DocStat{FileId: "a", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", WordCount: 100},
+		{RevId: "3", ModDate: "2016-01-03T10:00:00.000Z", WordCount: 90},
+	}}
+	days := UpdateDailyUserStat(docA, newA, load)
+	for _, d := range days {
+		stored[d.ModDate] = d
+	}
+
+	if len(days) != 3 {
+		t.Errorf("Expected 3 affected days got %d", len(days))
+	}
+	if d := stored["2016-01-02"]; d.WordAdd != 40 || len(d.FileRevs) != 1 || d.FileRevs["b"][0] != "1" {
+		t.Errorf("Other file clobbered %v", d)
+	}
+	if d := stored["2016-01-03"]; d.WordSub != -10 || d.FileRevs["a"][0] != "3" {
+		t.Errorf("New day wrong %v", d)
+	}
+
+	full := CreateDailyUserStat([]*DocStat{newA, docB})
+	for k, v := range full {
+		if stored[k].WordAdd != v.WordAdd || stored[k].WordSub != v.WordSub || len(stored[k].FileRevs) != len(v.FileRevs) {
+			t.Errorf("[%s] Incremental %v != rebuild %v", k, stored[k], v)
+		}
+	}
+
+	if !stored["2016-01-02"].HasFileWords() || (DailyUserStat{FileRevs: map[string][]string{"a": {"1"}}}).HasFileWords() {
+		t.Error("HasFileWords wrong")
+	}
+}
+
+func TestLength(t *testing.T) {
+	chars, noSpaces := CharCounts("\uFEFFThe cat sat.\r\nOn  a mat.\n")
+	if chars != 22 || noSpaces != 17 {
+		t.Errorf("Char counts %d %d", chars, noSpaces)
+	}
+
+	set := DefaultSettings()
+	set.WordsPerPage = 300
+	rev := RevStat{}
+	CalcRevStat(&rev, strings.Repeat("word ", 600), set)
+	if rev.Pages != 2 || rev.ReadMinutes != 600.0/DefaultReadingWPM || rev.Chars != 3000 || rev.CharsNoSpaces != 2400 {
+		t.Errorf("Length wrong %d %d %f %f", rev.Chars, rev.CharsNoSpaces, rev.Pages, rev.ReadMinutes)
+	}
+
+	doc := &DocStat{FileId: "a", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", WordCount: 10, Chars: 50},
+		{RevId: "2", ModDate: "2016-01-01T11:00:00.000Z", WordCount: 8, Chars: 60},
+	}}
+	day := CreateDailyUserStat([]*DocStat{doc})["2016-01-01"]
+	r := NewRollupStat(RollupWeek, "2015-W53")
+	r.SetDay(&day)
+	if day.CharAdd != 60 || day.CharSub != 0 || r.CharAdd != 60 {
+		t.Errorf("Char rollup wrong %+v %+v", day, r)
+	}
+}
+
+func TestChurn(t *testing.T) {
+	td := []struct {
+		a, b     string
+		ins, del int
+	}{
+		{"", "the cat sat", 3, 0},
+		{"the cat sat", "the cat sat", 0, 0},
+		{"the cat sat on the mat", "the dog sat on the mat", 1, 1},
+		{"the cat sat on the mat", "on the mat the cat sat", 3, 3},
+		{"a b c d", "a c d e", 1, 1},
+	}
+
+	for i, v := range td {
+		ins, del := DiffTokens(tokenizeDefault(v.a), tokenizeDefault(v.b))
+		if ins != v.ins || del != v.del {
+			t.Errorf("[%d] %d/%d != %d/%d", i, ins, del, v.ins, v.del)
+		}
+	}
+
+	if ins, del := bagDiff([]string{"a", "b", "b"}, []string{"b", "c"}); ins != 1 || del != 2 {
+		t.Errorf("Bag diff %d/%d", ins, del)
+	}
+
+	rev := RevStat{}
+	CalcChurn(&rev, "one two three four", "one two five four", TokenModeDefault)
+	if rev.Churn != 0.5 {
+		t.Errorf("Churn %f", rev.Churn)
+	}
+
+	days := []DailyUserStat{
+		{TokensInserted: 100, TokensDeleted: 5},
+		{TokensInserted: 50, TokensDeleted: 50},
+		{TokensInserted: 70, TokensDeleted: 30},
+		{WordAdd: 10, WordSub: -40},
+		{},
+	}
+	expect := []string{DayDrafting, DayRevising, DayMixed, DayRevising, ""}
+	for i, d := range days {
+		if d.DayKind() != expect[i] {
+			t.Errorf("[%d] %s != %s", i, d.DayKind(), expect[i])
+		}
+	}
+}
+
+func TestVelocity(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := make([]*DailyUserStat, 44)
+	dates := make([]time.Time, 44)
+	for i := range days {
+		dates[i] = start.AddDate(0, 0, i)
+		if i%2 == 0 {
+			days[i] = &DailyUserStat{WordAdd: 70}
+		}
+	}
+	// A big final week
+	for i := 37; i < 44; i++ {
+		days[i] = &DailyUserStat{WordAdd: 100}
+	}
+
+	v := CalcVelocity(days, dates, RollingLong)
+	if len(v.Days) != 14 || v.Days[0].Date != "2016-01-31" {
+		t.Errorf("Wrong days %d %v", len(v.Days), v.Days)
+	}
+	if v.Days[0].Avg7 != 40 || v.Days[0].Avg30 != 70*15/30.0 {
+		t.Errorf("Averages wrong %+v", v.Days[0])
+	}
+	if v.Days[13].Avg7 != 100 {
+		t.Errorf("Last average %+v", v.Days[13])
+	}
+	if v.ThisWeek != 700 || v.LastWeek != 280 || v.WeekChange != 150 {
+		t.Errorf("Week change %d %d %f", v.ThisWeek, v.LastWeek, v.WeekChange)
+	}
+	if v.BestWeek.Words != 700 || v.BestWeek.Start != "2016-02-07" || v.WorstWeek.Words != 210 {
+		t.Errorf("Best/Worst %v %v", v.BestWeek, v.WorstWeek)
+	}
+}
+
+func TestTFIDF(t *testing.T) {
+	set := DefaultSettings()
+	set.Stemming = false
+
+	dragons := CalcDocTerms("a", "Dragons", "The dragon flew. The dragon burned the castle. The knight ran from the castle.", set)
+	ships := CalcDocTerms("b", "Ships", "The ship sailed. The ship sank near the castle. The castle watched the ship.", set)
+
+	c := NewCorpus()
+	c.Add(dragons)
+	c.Add(ships)
+	if c.Docs != 2 || c.DocFreq["castle"] != 2 || c.DocFreq["dragon"] != 1 {
+		t.Errorf("Corpus wrong %+v", c)
+	}
+
+	top := c.Distinctive(dragons, 5)
+	if len(top) != 2 || top[0].Word != "dragon" || top[1].Word != "castle" {
+		t.Errorf("Distinctive wrong %v", top)
+	}
+
+	tags := c.TagCloud([]*DocTerms{dragons, ships}, "")
+	if len(tags) != 3 || tags[0].Word != "ship" || tags[0].Size != 5 || tags[1].Word != "castle" || len(tags[1].FileIds) != 2 {
+		t.Errorf("Tag cloud wrong %+v", tags)
+	}
+	if found := c.TagCloud([]*DocTerms{dragons, ships}, "DRAG"); len(found) != 1 || found[0].Word != "dragon" {
+		t.Errorf("Tag search wrong %+v", found)
+	}
+
+	c.Remove(ships)
+	if c.Docs != 1 || c.DocFreq["castle"] != 1 || c.DocFreq["ship"] != 0 {
+		t.Errorf("Remove wrong %+v", c)
+	}
+}
+
+func TestMinHash(t *testing.T) {
+	base := strings.Repeat("It was a dark and stormy night and the rain fell in torrents. ", 3) +
+		"Except at occasional intervals, when it was checked by a violent gust of wind which swept up the streets. " +
+		"For it is in London that our scene lies, rattling along the housetops and fiercely agitating the scanty flame of the lamps."
+	other := "Call me Ishmael. Some years ago, never mind how long precisely, having little or no money in my purse, " +
+		"and nothing particular to interest me on shore, I thought I would sail about a little and see the watery part of the world."
+
+	fpBase := CalcFingerprint(base, TokenModeDefault)
+	if Similarity(fpBase, CalcFingerprint(strings.ToUpper(base), TokenModeDefault)) != 1 {
+		t.Error("Case should not change the fingerprint")
+	}
+	if s := Similarity(fpBase, CalcFingerprint(other, TokenModeDefault)); s > 0.1 {
+		t.Errorf("Different texts too similar %f", s)
+	}
+	if CalcFingerprint("", TokenModeDefault) != nil || Similarity(nil, fpBase) != 0 {
+		t.Error("Empty text should have no fingerprint")
+	}
+
+	orig := &DocStat{FileId: "orig", Title: "Original", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", WordCount: 100},
+	}, FirstFingerprint: fpBase, Fingerprint: fpBase}
+	copied := &DocStat{FileId: "copy", Title: "Copy", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-05T10:00:00.000Z", WordCount: 100},
+		{RevId: "2", ModDate: "2016-01-05T11:00:00.000Z", WordCount: 150},
+	}, FirstFingerprint: fpBase, Fingerprint: CalcFingerprint(base+" "+other, TokenModeDefault)}
+	unrelated := &DocStat{FileId: "other", Title: "Other", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-02T10:00:00.000Z", WordCount: 40},
+	}, FirstFingerprint: CalcFingerprint(other, TokenModeDefault), Fingerprint: CalcFingerprint(other, TokenModeDefault)}
+	prints := DocPrints([]*DocStat{orig, copied, unrelated})
+
+	rel := RelatedDocs(copied.Print(), prints)
+	if len(rel) != 2 || rel[0].Kind != RelatedCopyOf || rel[0].FileId != "orig" || rel[1].Kind != RelatedOverlap {
+		t.Errorf("Copy relations wrong %v", rel)
+	}
+	if rel := RelatedDocs(orig.Print(), prints); len(rel) != 1 || rel[0].Kind != RelatedCopiedTo {
+		t.Errorf("Original relations wrong %v", rel)
+	}
+
+	FlagCopies(copied, prints)
+	FlagCopies(orig, prints)
+	if !copied.RevList[0].IsImported() || copied.RevList[1].IsImported() || orig.RevList[0].IsImported() {
+		t.Errorf("Copy flags wrong %v %v", copied.RevList, orig.RevList)
+	}
+	if day := CreateDailyUserStat([]*DocStat{copied})["2016-01-05"]; day.WordAdd != 50 || day.WordImported != 100 {
+		t.Errorf("Copied words counted %v", day)
+	}
+}
+
+func TestStyleDrift(t *testing.T) {
+	doc := &DocStat{FileId: "a", Title: "Book", RevList: []RevStat{
+		{ModDate: "2016-01-01T10:00:00.000Z", WordCount: 100,
+			WordFreq:     []WordPair{{Word: "the", Count: 10}, {Word: "cat", Count: 5}},
+			ContentWords: []WordPair{{Word: "cat", Count: 5}, {Word: "mat", Count: 5}},
+			Readability:  Readability{Sentences: 10, AvgSentenceLength: 10}},
+		{ModDate: "2016-01-02T10:00:00.000Z", WordCount: 120,
+			WordFreq:    []WordPair{{Word: "the", Count: 10}, {Word: "cat", Count: 10}},
+			Readability: Readability{Sentences: 10, AvgSentenceLength: 12}},
+		{ModDate: "2016-02-01T10:00:00.000Z", WordCount: 200,
+			WordFreq:    []WordPair{{Word: "the", Count: 10}, {Word: "ship", Count: 10}},
+			Readability: Readability{Sentences: 10, AvgSentenceLength: 20}},
+	}}
+
+	jan := ProfileRange([]*DocStat{doc}, "2016-01-01", "2016-01-31", nil)
+	feb := ProfileRange([]*DocStat{doc}, "2016-02-01", "2016-02-29", nil)
+	if jan.Docs != 1 || jan.Freq["cat"] != 10 || jan.AvgSentenceLength != 12 {
+		t.Errorf("Jan profile wrong %+v", jan)
+	}
+
+	r := CompareProfiles(jan, feb)
+	if r.Divergence < 0.49 || r.Divergence > 0.51 || r.SentenceChange != 8 {
+		t.Errorf("Drift wrong %v", r)
+	}
+	if len(r.Gained) != 1 || r.Gained[0].Word != "ship" || len(r.Lost) != 1 || r.Lost[0].Word != "cat" {
+		t.Errorf("Shifts wrong %v %v", r.Gained, r.Lost)
+	}
+
+	if same := CompareProfiles(jan, jan); same.Divergence != 0 || len(same.Gained) != 0 {
+		t.Errorf("Same profile drifted %v", same)
+	}
+
+	first := ProfileRange([]*DocStat{doc}, "2016-01-01", "2016-01-01", nil)
+	if first.Freq["the"] != 10 || first.Freq["cat"] != 5 || first.Freq["mat"] != 5 {
+		t.Errorf("Word lists not merged %v", first.Freq)
+	}
+	if ProfileDoc(doc, nil).Freq["ship"] != 10 {
+		t.Error("Doc profile should use latest revision")
+	}
+
+	// A word used under the top ten is still counted from the stored text
+	common := strings.Repeat("alpha bravo charlie delta echo foxtrot golf hotel india juliet ", 5)
+	texts := map[string]string{
+		"1": common + "kilo kilo",
+		"2": common + "kilo kilo lima",
+	}
+	short := &DocStat{FileId: "b", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-03-01T10:00:00.000Z"},
+		{RevId: "2", ModDate: "2016-04-01T10:00:00.000Z"},
+	}}
+	text := func(fileId string, revId string) (string, bool) {
+		t, ok := texts[revId]
+		return t, ok && fileId == "b"
+	}
+	r = CompareProfiles(ProfileRange([]*DocStat{short}, "2016-03-01", "2016-03-31", text), ProfileRange([]*DocStat{short}, "2016-04-01", "2016-04-30", text))
+	if r.A.Freq["kilo"] != 2 || r.B.Freq["kilo"] != 2 {
+		t.Errorf("Words outside the top ten missing %v", r.B.Freq)
+	}
+	for _, ws := range r.Lost {
+		if ws.Word == "kilo" && ws.ShareB == 0 {
+			t.Errorf("kilo reported lost %v", r.Lost)
+		}
+	}
+	if len(r.Gained) != 1 || r.Gained[0].Word != "lima" {
+		t.Errorf("Gained wrong %v", r.Gained)
+	}
+}
+
+func TestFillers(t *testing.T) {
+	set := DefaultSettings()
+	set.EchoWindow = 5
+
+	text := "She just really ran quickly. The family was only early. The door opened and the door closed, far from any door."
+	fs := CalcFillers(text, set)
+	if fs.Total != 3 {
+		t.Errorf("Fillers %d %v", fs.Total, fs.Counts)
+	}
+	if fs.Echoes != 2 || fs.EchoWords[0].Word != "door" {
+		t.Errorf("Echoes %d %v", fs.Echoes, fs.EchoWords)
+	}
+
+	set.FillerWords = []string{"door"}
+	set.EchoWindow = 0
+	if fs := CalcFillers(text, set); fs.Total != 3 || fs.Echoes != 0 {
+		t.Errorf("Custom fillers %v", fs)
+	}
+
+	doc := &DocStat{RevList: []RevStat{
+		{Fillers: FillerStat{Counts: []WordPair{{Word: "just", Count: 4}, {Word: "very", Count: 1}}}},
+		{Fillers: FillerStat{Counts: []WordPair{{Word: "just", Count: 1}, {Word: "very", Count: 2}}}},
+	}}
+	trends := DocFillerTrends(doc)
+	if len(trends) != 2 || trends[0].Phrase != "very" || trends[0].Reduced || trends[1].Phrase != "just" || !trends[1].Reduced {
+		t.Errorf("Filler trends %v", trends)
+	}
+
+	doc.RevList[1].Tracked = &TrackedCounts{Fillers: map[string]int{"just": 4, "very": 2}}
+	if trends := DocFillerTrends(doc); trends[0].Phrase != "just" || trends[0].Reduced || trends[0].Latest != 4 {
+		t.Errorf("Tracked filler trends %v", trends)
+	}
+}
+
+func TestGlossary(t *testing.T) {
+	glossary := []GlossaryEntry{
+		{Name: "Mary Jane", Kind: EntityCharacter, Aliases: []string{"Mary", "MJ"}},
+		{Name: "Rome", Kind: EntityPlace, CaseSensitive: true},
+		{Name: "Zoë", Kind: EntityCharacter},
+	}
+
+	counts := CountEntities("Mary Jane went to Rome. mary waved, MJ smiled. Maryland and romeo are not names. ZOË met Zoë in rome.", glossary)
+	if counts["Mary Jane"] != 3 || counts["Rome"] != 1 || counts["Zoë"] != 2 {
+		t.Errorf("Entity counts %v", counts)
+	}
+	if CountEntities("Mary", nil) != nil {
+		t.Error("No glossary should count nothing")
+	}
+
+	docA := &DocStat{Title: "A", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z", Entities: map[string]int{"Rome": 0}},
+		{RevId: "2", ModDate: "2016-01-02T10:00:00.000Z", Entities: map[string]int{"Rome": 2}},
+		{RevId: "3", ModDate: "2016-01-04T10:00:00.000Z", Entities: map[string]int{"Rome": 0}},
+	}}
+	docB := &DocStat{Title: "B", RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-03T10:00:00.000Z", Entities: map[string]int{"Rome": 3}},
+	}}
+
+	series := DocEntitySeries(docA, glossary[1:2])
+	if es := series[0]; es.First.RevId != "2" || es.Last.RevId != "2" || es.Peak != 2 || es.Latest != 0 {
+		t.Errorf("Doc series %+v", es)
+	}
+
+	series = ProjectEntitySeries([]*DocStat{docA, docB}, glossary[1:2])
+	expect := []int{0, 2, 5, 3}
+	for i, p := range series[0].Points {
+		if p.Count != expect[i] {
+			t.Errorf("[%d] %d != %d", i, p.Count, expect[i])
+		}
+	}
+	if es := series[0]; es.First.Date != "2016-01-02" || es.Last.RevId != "A rev 3" || es.Peak != 5 {
+		t.Errorf("Project series %+v", es)
+	}
+
+	texts := map[string]string{"1": "Rome", "2": "Rome and Mary"}
+	RecountEntities(docA, glossary[:2], func(revId string) (string, bool) {
+		t, ok := texts[revId]
+		return t, ok
+	})
+	if docA.RevList[0].Entities["Rome"] != 1 || docA.RevList[1].Entities["Mary Jane"] != 1 || len(docA.RevList[2].Entities) != 1 {
+		t.Errorf("Recount %v", docA.RevList)
+	}
+
+	p := &Project{}
+	p.AddEntry(glossary[0])
+	p.AddEntry(GlossaryEntry{Name: "Mary Jane", Kind: EntityTerm})
+	p.AddEntry(glossary[1])
+	p.RemoveEntry("Rome")
+	if len(p.Glossary) != 1 || p.Glossary[0].Kind != EntityTerm {
+		t.Errorf("Glossary edits %v", p.Glossary)
+	}
+}
+
+func TestProse(t *testing.T) {
+	dialogue, narration := SplitDialogue("She said, ‘Go home.’ The dogs' bowls weren't “full” yet.")
+	if strings.Fields(dialogue)[0] != "Go" || strings.Contains(narration, "Go") || !strings.Contains(dialogue, "full") || !strings.Contains(narration, "weren't") {
+		t.Errorf("Split wrong [%s] [%s]", dialogue, narration)
+	}
+
+	long := strings.Repeat("word ", 45)
+	text := "\"Stop!\" said Bob.\n\nShe said, ‘Go home.’ The dogs' bowls were empty.\n\"It was long ago,\n\"and far away.\"\n" + long
+	ps := CalcProse(text, TokenModeDefault)
+	if ps.DialogueWords != 10 || ps.NarrationWords != 54 || ps.Paragraphs != 5 || ps.DialogueParagraphs != 4 || ps.LongestParagraph != 45 {
+		t.Errorf("Prose wrong %+v", ps)
+	}
+	if ps.ParagraphHist[0] != 4 || ps.ParagraphHist[2] != 1 || ps.AvgParagraph != 12.8 {
+		t.Errorf("Paragraph lengths wrong %v %.1f", ps.ParagraphHist, ps.AvgParagraph)
+	}
+	if ps.DialogueShare() != 10.0/64 || ps.DialogueRatio() != 10.0/54 {
+		t.Errorf("Dialogue share %.3f ratio %.3f", ps.DialogueShare(), ps.DialogueRatio())
+	}
+
+	sections := CalcSections(ParseHTMLBlocks("<h1>One</h1><p>&ldquo;Hi there,&rdquo; said Ann.</p><p>Nobody spoke.</p>"), TokenModeDefault)
+	if sections[0].Words != 6 || sections[0].DialogueWords != 2 || sections[0].DialogueShare() != 2.0/6 {
+		t.Errorf("Section dialogue %+v", sections[0])
+	}
+}
+
+func TestBlame(t *testing.T) {
+	revs := []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z"},
+		{RevId: "2", ModDate: "2016-01-02T10:00:00.000Z"},
+		{RevId: "3", ModDate: "2016-01-03T10:00:00.000Z"},
+	}
+	texts := []string{
+		"Alpha one two three.\nBeta four five six.\n",
+		"Beta four five six.\n\nAlpha one two three four.\nGamma new.\n",
+		"Beta four five six.\nTotally different words here.\n",
+	}
+
+	blame := &DocBlame{}
+	UpdateBlame(blame, revs[0], texts[0], TokenModeDefault)
+	UpdateBlame(blame, revs[1], texts[1], TokenModeDefault)
+	expect := [][2]string{{"1", "1"}, {"1", "2"}, {"2", "2"}}
+	if len(blame.Paragraphs) != len(expect) {
+		t.Fatalf("Blame wrong %v", blame.Paragraphs)
+	}
+	for i, v := range expect {
+		if pb := blame.Paragraphs[i]; pb.FirstRevId != v[0] || pb.LastRevId != v[1] {
+			t.Errorf("[%d] %v expected %v", i, pb, v)
+		}
+	}
+	if blame.Paragraphs[0].Changed() || !blame.Paragraphs[1].Changed() {
+		t.Errorf("Changed wrong %v", blame.Paragraphs)
+	}
+
+	UpdateBlame(blame, revs[2], texts[2], TokenModeDefault)
+	if len(blame.Paragraphs) != 2 || blame.Paragraphs[0].FirstRevId != "1" || blame.Paragraphs[1].FirstRevId != "3" || blame.RevId != "3" {
+		t.Errorf("Blame after cut %v", blame.Paragraphs)
+	}
+
+	now := time.Date(2016, 1, 5, 12, 0, 0, 0, time.UTC)
+	if a := BlameAge("2016-01-03T10:00:00.000Z", now); a != 1 {
+		t.Errorf("Age %d", a)
+	}
+	if a := BlameAge("2014-01-03T10:00:00.000Z", now); a != len(BlameAgeDays) {
+		t.Errorf("Old age %d", a)
+	}
+	if len(BlameAgeLabels()) != len(BlameAgeDays)+1 {
+		t.Errorf("Labels %v", BlameAgeLabels())
+	}
+}
+
+func TestCuts(t *testing.T) {
+	set := DefaultSettings()
+	set.CutWords = 10
+	prev := "Keep this opening line.\nGone paragraph one has exactly seven words.\nGone paragraph two has six words.\nMiddle stays.\n" +
+		"First kept sentence has some words in it. This removed sentence is the one that we expect to find cut. Last kept sentence is here too.\nTiny gone.\n"
+	text := "Keep this opening line.\nMiddle stays.\nFirst kept sentence has some words in it. Last kept sentence is here too.\n"
+
+	rev := RevStat{RevId: "2", ModDate: "2016-01-02T10:00:00.000Z"}
+	cuts := FindCuts("f1", "Novel", rev, prev, text, set)
+	if len(cuts) != 2 {
+		t.Fatalf("Cuts wrong %v", cuts)
+	}
+	if cuts[0].Words != 13 || cuts[0].Text != "Gone paragraph one has exactly seven words.\nGone paragraph two has six words." {
+		t.Errorf("Paragraph cut %v", cuts[0])
+	}
+	if cuts[1].Words != 12 || cuts[1].Text != "This removed sentence is the one that we expect to find cut." || cuts[1].RevId != "2" || cuts[1].Title != "Novel" {
+		t.Errorf("Sentence cut %v", cuts[1])
+	}
+	if len(FindCuts("f1", "Novel", rev, "", text, set)) != 0 {
+		t.Error("First revision has nothing to cut")
+	}
+
+	if found := SearchCuts(cuts, "REMOVED"); len(found) != 1 || found[0].Words != 12 {
+		t.Errorf("Search %v", found)
+	}
+	if found := SearchCuts(cuts, "novel"); len(found) != 2 {
+		t.Errorf("Title search %v", found)
+	}
+
+	cuts[1].ModDate = "2016-01-05T10:00:00.000Z"
+	sort.Sort(CutsByDate(cuts))
+	if cuts[0].Words != 12 {
+		t.Errorf("Newest first %v", cuts)
+	}
+
+	long := strings.Repeat("This long paragraph says the same thing again. ", 600)
+	prev = long + "Old ending.\nThis whole paragraph was taken out of the draft today.\n"
+	text = long + "New ending.\n"
+	cuts = FindCuts("f1", "Novel", rev, prev, text, set)
+	if len(cuts) != 1 || cuts[0].Text != "This whole paragraph was taken out of the draft today." {
+		t.Errorf("Over the limit cuts %v", cuts)
+	}
+}
+
+func TestEditMap(t *testing.T) {
+	texts := []string{
+		"Alpha one two three.\nBeta four five six.\nGamma seven eight nine.\nDelta ten eleven twelve.\n",
+		"Alpha one two three.\nBeta four five six.\nGamma seven eight nine.\nDelta ten eleven twelve thirteen.\n",
+		"Beta four five six.\nGamma seven eight nine.\nDelta ten eleven twelve thirteen.\n",
+	}
+	doc := &DocStat{RevList: []RevStat{
+		{RevId: "1", ModDate: "2016-01-01T10:00:00.000Z"},
+		{RevId: "2", ModDate: "2016-01-03T10:00:00.000Z"},
+		{RevId: "3", ModDate: "2016-01-03T12:00:00.000Z", ImportOverride: ImportOverrideImported},
+	}}
+	prevText := ""
+	for i, text := range texts {
+		CalcEditSpots(&doc.RevList[i], prevText, text, TokenModeDefault)
+		prevText = text
+	}
+
+	if e := doc.RevList[0].Edits; len(e) != 4 || e[1] != (EditSpot{25, 4}) || e[3] != (EditSpot{75, 4}) {
+		t.Errorf("First revision spots %v", e)
+	}
+	if e := doc.RevList[1].Edits; len(e) != 1 || e[0] != (EditSpot{70, 1}) {
+		t.Errorf("Edit spots %v", e)
+	}
+	if e := doc.RevList[2].Edits; len(e) != 1 || e[0] != (EditSpot{0, 4}) {
+		t.Errorf("Cut spots %v", e)
+	}
+
+	em := DocEditMap(doc)
+	if len(em.Days) != 3 || em.From != "2016-01-01" || em.To != "2016-01-03" || em.Total != 17 || em.Max != 4 {
+		t.Errorf("Edit map %v", em)
+	}
+	if em.Words[0][5] != 4 || em.Words[2][14] != 1 || em.Words[2][0] != 0 {
+		t.Errorf("Edit map bands %v", em.Words)
+	}
+}
+
+func TestProjectTarget(t *testing.T) {
+	doc := &DocStat{RevList: []RevStat{
+		{ModDate: "2016-01-01T10:00:00.000Z", WordCount: 100},
+		{ModDate: "2016-01-01T18:00:00.000Z", WordCount: 300},
+		{ModDate: "2016-01-10T09:00:00.000Z", WordCount: 1000},
+		{ModDate: "2016-01-15T09:00:00.000Z", WordCount: 1500},
+	}}
+
+	points := DocWordPoints(doc)
+	if len(points) != 3 || points[0].Words != 300 {
+		t.Fatalf("Word points wrong %v", points)
+	}
+
+	now := time.Date(2016, 1, 15, 20, 0, 0, 0, time.UTC)
+	tp := ProjectTarget(Target{Id: "doc", WordTarget: 3000, Deadline: "2016-01-25"}, points, now)
+
+	if tp.WordsLeft != 1500 || tp.DaysLeft != 10 {
+		t.Errorf("Words left %d days left %d", tp.WordsLeft, tp.DaysLeft)
+	}
+	if tp.RequiredPerDay != 150 {
+		t.Errorf("Required per day %f", tp.RequiredPerDay)
+	}
+	if tp.TrailingPace != 1200.0/TargetPaceDays {
+		t.Errorf("Trailing pace %f", tp.TrailingPace)
+	}
+	if tp.ProjectedDate != "2016-02-02" || tp.OnTrack {
+		t.Errorf("Projected %s on track %v", tp.ProjectedDate, tp.OnTrack)
+	}
+}
+
+func TestStem(t *testing.T) {
+	td := map[string]string{
+		"run":       "run",
+		"runs":      "run",
+		"running":   "run",
+		"hoping":    "hope",
+		"hopping":   "hop",
+		"opened":    "open",
+		"stories":   "story",
+		"glasses":   "glass",
+		"quickly":   "quick",
+		"agreed":    "agreed",
+		"listening": "listen",
+		"bring":     "bring",
+	}
+
+	for k, v := range td {
+		if s := Stem(k); s != v {
+			t.Errorf("Stem %s = %s expected %s", k, s, v)
+		}
+	}
+}
+
+func TestContentWords(t *testing.T) {
+	text := `The dog runs and the dog is running to the other dogs. Running is what the dog does.`
+
+	m, wc := wordCount(text)
+	stop := StopWordSet("en", []string{"Does"})
+
+	plain := topWordPairFromMap(contentWordMap(m, stop, false), wc, -1, 1)
+	if plain[0].Word != "dog" || plain[0].Count != 3 {
+		t.Errorf("Plain content words wrong %v", plain)
+	}
+
+	stemmed := topWordPairFromMap(contentWordMap(m, stop, true), wc, -1, 1)
+	if stemmed[0].Word != "dog" || stemmed[0].Count != 4 || stemmed[1].Word != "running" || stemmed[1].Count != 3 {
+		t.Errorf("Stemmed content words wrong %v", stemmed)
+	}
+
+	for _, v := range stemmed {
+		if v.Word == "the" || v.Word == "does" {
+			t.Errorf("Stop word %s not removed", v.Word)
+		}
+	}
+
+	if !IsStopLanguage("fr") || !IsStopLanguage("") || IsStopLanguage("xx") {
+		t.Error("IsStopLanguage wrong")
+	}
+}
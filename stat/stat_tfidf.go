@@ -0,0 +1,179 @@
+package stat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// Number of distinctive terms kept per document
+	DistinctiveTerms = 15
+	// Terms used fewer times than this are too rare to say what a doc is about
+	MinTermCount = 2
+)
+
+// DocTerms are the content word counts of the latest revision of a document
+type DocTerms struct {
+	FileId string         `json:"FileId"`
+	Title  string         `json:"Title"`
+	Words  int            `json:"Words"`
+	Terms  map[string]int `json:"Terms"`
+}
+
+// Corpus counts how many tracked documents use each term
+type Corpus struct {
+	Docs    int            `json:"Docs"`
+	DocFreq map[string]int `json:"DocFreq"`
+}
+
+// TermScore is a term ranked by TF-IDF
+type TermScore struct {
+	Word  string
+	Count int
+	Score float64
+}
+
+// Tag is a term that is distinctive in at least one document
+type Tag struct {
+	Word    string
+	Score   float64
+	Size    int
+	FileIds []string
+	Titles  []string
+}
+
+type termScoreByScore []TermScore
+
+func (a termScoreByScore) Len() int      { return len(a) }
+func (a termScoreByScore) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a termScoreByScore) Less(i, j int) bool {
+	if a[i].Score == a[j].Score {
+		return a[i].Word < a[j].Word
+	}
+	return a[i].Score > a[j].Score
+}
+
+type tagByScore []Tag
+
+func (a tagByScore) Len() int      { return len(a) }
+func (a tagByScore) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a tagByScore) Less(i, j int) bool {
+	if a[i].Score == a[j].Score {
+		return a[i].Word < a[j].Word
+	}
+	return a[i].Score > a[j].Score
+}
+
+func (ts TermScore) String() string {
+	return fmt.Sprintf("%s:%d (%.4f)", ts.Word, ts.Count, ts.Score)
+}
+
+// CalcDocTerms counts the content words of a document's text
+func CalcDocTerms(fileId string, title string, text string, set *Settings) *DocTerms {
+	m, wc := wordCountMode(text, set.TokenMode)
+	stop := StopWordSet(set.StopLanguage, set.StopWords)
+
+	return &DocTerms{
+		FileId: fileId,
+		Title:  title,
+		Words:  wc,
+		Terms:  contentWordMap(m, stop, set.Stemming),
+	}
+}
+
+func NewCorpus() *Corpus {
+	return &Corpus{DocFreq: make(map[string]int)}
+}
+
+// Add counts a document's terms into the corpus
+func (c *Corpus) Add(dt *DocTerms) {
+	if c.DocFreq == nil {
+		c.DocFreq = make(map[string]int)
+	}
+
+	c.Docs++
+	for w := range dt.Terms {
+		c.DocFreq[w]++
+	}
+}
+
+// Remove takes a document's terms back out of the corpus
+func (c *Corpus) Remove(dt *DocTerms) {
+	c.Docs--
+	for w := range dt.Terms {
+		c.DocFreq[w]--
+		if c.DocFreq[w] <= 0 {
+			delete(c.DocFreq, w)
+		}
+	}
+}
+
+// IDF is the smoothed inverse document frequency of a term
+func (c *Corpus) IDF(word string) float64 {
+	return math.Log(float64(1+c.Docs)/float64(1+c.DocFreq[word])) + 1
+}
+
+// Distinctive ranks a document's terms by TF-IDF against the corpus
+func (c *Corpus) Distinctive(dt *DocTerms, n int) []TermScore {
+	if dt == nil || dt.Words == 0 {
+		return nil
+	}
+
+	scores := []TermScore{}
+	for w, count := range dt.Terms {
+		if count < MinTermCount {
+			continue
+		}
+		tf := float64(count) / float64(dt.Words)
+		scores = append(scores, TermScore{Word: w, Count: count, Score: tf * c.IDF(w)})
+	}
+
+	sort.Sort(termScoreByScore(scores))
+	if n > 0 && len(scores) > n {
+		scores = scores[:n]
+	}
+
+	return scores
+}
+
+// TagCloud gathers the distinctive terms of every document, sized 1 to 5 by
+// their summed score. A non blank filter keeps tags containing it.
+func (c *Corpus) TagCloud(docs []*DocTerms, filter string) []Tag {
+	filter = strings.ToLower(filter)
+
+	tags := make(map[string]*Tag)
+	for _, dt := range docs {
+		for _, ts := range c.Distinctive(dt, DistinctiveTerms) {
+			if filter != "" && !strings.Contains(ts.Word, filter) {
+				continue
+			}
+
+			t, ok := tags[ts.Word]
+			if !ok {
+				t = &Tag{Word: ts.Word}
+				tags[ts.Word] = t
+			}
+			t.Score += ts.Score
+			t.FileIds = append(t.FileIds, dt.FileId)
+			t.Titles = append(t.Titles, dt.Title)
+		}
+	}
+
+	result := []Tag{}
+	maxScore := 0.0
+	for _, t := range tags {
+		result = append(result, *t)
+		if t.Score > maxScore {
+			maxScore = t.Score
+		}
+	}
+
+	for i := range result {
+		result[i].Size = 1 + int(4*result[i].Score/maxScore)
+	}
+
+	sort.Sort(tagByScore(result))
+	return result
+}
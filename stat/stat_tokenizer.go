@@ -0,0 +1,133 @@
+package stat
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Tokenizer splits text into the words that get counted
+type Tokenizer func(s string) []string
+
+const (
+	// Original counting, splits on anything but letters, numbers, ` and '
+	TokenModeDefault = "default"
+	// Matches the Google Docs word count as closely as we can
+	TokenModeGoogleDocs = "google-docs"
+	// Anything between whitespace is a word
+	TokenModeWhitespace = "whitespace"
+	// Only runs of letters count, numbers and punctuation are not words
+	TokenModeStrict = "strict"
+)
+
+var tokenizers = map[string]Tokenizer{
+	TokenModeDefault:    tokenizeDefault,
+	TokenModeGoogleDocs: tokenizeGoogleDocs,
+	TokenModeWhitespace: tokenizeWhitespace,
+	TokenModeStrict:     tokenizeStrict,
+}
+
+// GetTokenizer returns the named tokenizer, unknown modes fall back to default.
+// Apart from whitespace mode CJK text is counted a character per word.
+func GetTokenizer(mode string) Tokenizer {
+	t, ok := tokenizers[mode]
+	if !ok {
+		t = tokenizeDefault
+	}
+
+	if mode == TokenModeWhitespace {
+		return t
+	}
+
+	return func(s string) []string {
+		return splitCJK(t(s))
+	}
+}
+
+// IsTokenMode reports if mode names a known tokenizer
+func IsTokenMode(mode string) bool {
+	_, ok := tokenizers[mode]
+	return ok
+}
+
+// TokenModes lists the names of all tokenizers
+func TokenModes() []string {
+	modes := []string{}
+	for k := range tokenizers {
+		modes = append(modes, k)
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+func tokenizeDefault(s string) []string {
+	f := func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c) && (c != '`') && (c != '\'')
+	}
+
+	return strings.FieldsFunc(s, f)
+}
+
+func tokenizeWhitespace(s string) []string {
+	return strings.Fields(s)
+}
+
+func hasLetterOrNumber(s string) bool {
+	return strings.IndexFunc(s, func(c rune) bool {
+		return unicode.IsLetter(c) || unicode.IsNumber(c)
+	}) >= 0
+}
+
+func isURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(strings.ToLower(s), "www.")
+}
+
+// Google Docs counts whitespace separated words, hyphenated words and URLs
+// are one word, dashes and ellipses break words and bare punctuation is skipped
+func tokenizeGoogleDocs(s string) []string {
+	breakers := strings.NewReplacer("—", " ", "–", " ", "…", " ", "...", " ")
+
+	words := []string{}
+	for _, field := range strings.Fields(s) {
+		parts := []string{field}
+		if !isURL(field) {
+			parts = strings.Fields(breakers.Replace(field))
+		}
+
+		for _, p := range parts {
+			if hasLetterOrNumber(p) {
+				words = append(words, p)
+			}
+		}
+	}
+
+	return words
+}
+
+// Strict counts runs of letters, apostrophes inside a word are kept but
+// hyphens, numbers and everything else split words
+func tokenizeStrict(s string) []string {
+	runes := []rune(s)
+	words := []string{}
+	start := -1
+
+	for i, c := range runes {
+		inWord := unicode.IsLetter(c)
+		if !inWord && (c == '\'' || c == '’') && start >= 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]) {
+			inWord = true
+		}
+
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			words = append(words, string(runes[start:i]))
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
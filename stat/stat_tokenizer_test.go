@@ -0,0 +1,84 @@
+package stat
+
+import "testing"
+
+type tokenFixture struct {
+	text      string
+	wordCount int
+}
+
+var tokenText = []string{
+	`I think I may do a bit too much soemtimes...got a half written Vulkan renderer in
+		  flight atm too.`,
+	`A well-known new-fangled idea`,
+	`See https://example.com/some-page.html or www.example.org for more`,
+	`She paused—then ran – fast… very fast`,
+	`It's 2015 & we've got 3 docs`,
+}
+
+func testTokenFixtures(t *testing.T, mode string, fixtures []tokenFixture) {
+	tok := GetTokenizer(mode)
+	for i, v := range fixtures {
+		words := tok(v.text)
+		if len(words) != v.wordCount {
+			t.Errorf("[%s %d] Word Count failed %d != %d %q", mode, i, len(words), v.wordCount, words)
+		}
+	}
+}
+
+func TestTokenizeDefault(t *testing.T) {
+	testTokenFixtures(t, TokenModeDefault, []tokenFixture{
+		{tokenText[0], 20},
+		{tokenText[1], 6},
+		{tokenText[2], 13},
+		{tokenText[3], 7},
+		{tokenText[4], 6},
+	})
+}
+
+func TestTokenizeGoogleDocs(t *testing.T) {
+	testTokenFixtures(t, TokenModeGoogleDocs, []tokenFixture{
+		{tokenText[0], 20},
+		{tokenText[1], 4},
+		{tokenText[2], 6},
+		{tokenText[3], 7},
+		{tokenText[4], 6},
+	})
+}
+
+func TestTokenizeGoogleDocsCJK(t *testing.T) {
+	testTokenFixtures(t, TokenModeGoogleDocs, []tokenFixture{
+		{`「我爱你。」她说：“你爱我吗？”`, 9},
+		{`我爱你 。 你爱我吗？`, 7},
+		{`I wrote 東京。 today`, 5},
+	})
+}
+
+func TestTokenizeWhitespace(t *testing.T) {
+	testTokenFixtures(t, TokenModeWhitespace, []tokenFixture{
+		{tokenText[0], 19},
+		{tokenText[1], 4},
+		{tokenText[2], 6},
+		{tokenText[3], 7},
+		{tokenText[4], 7},
+	})
+}
+
+func TestTokenizeStrict(t *testing.T) {
+	testTokenFixtures(t, TokenModeStrict, []tokenFixture{
+		{tokenText[0], 20},
+		{tokenText[1], 6},
+		{tokenText[2], 13},
+		{tokenText[3], 7},
+		{tokenText[4], 4},
+	})
+}
+
+func TestTokenModeFallback(t *testing.T) {
+	if len(GetTokenizer("unknown")(tokenText[0])) != 20 {
+		t.Error("Unknown mode should fall back to default")
+	}
+	if IsTokenMode("unknown") || !IsTokenMode(TokenModeGoogleDocs) {
+		t.Error("IsTokenMode wrong")
+	}
+}
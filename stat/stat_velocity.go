@@ -0,0 +1,126 @@
+package stat
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RollingShort = 7
+	RollingLong  = 30
+)
+
+// VelocityDay is the words added on a day with the trailing averages
+type VelocityDay struct {
+	Date  string
+	Words int
+	Avg7  float64
+	Avg30 float64
+}
+
+// VelocityPeriod is a run of days and the words added over it
+type VelocityPeriod struct {
+	Start string
+	End   string
+	Words int
+}
+
+// Velocity smooths daily word counts and compares recent weeks
+type Velocity struct {
+	Days       []VelocityDay
+	ThisWeek   int
+	LastWeek   int
+	WeekChange float64 // percent change on the 7 days before
+	BestWeek   VelocityPeriod
+	WorstWeek  VelocityPeriod
+	BestMonth  VelocityPeriod
+	WorstMonth VelocityPeriod
+}
+
+func (p VelocityPeriod) String() string {
+	return fmt.Sprintf("%s to %s: %d words", p.Start, p.End, p.Words)
+}
+
+// CalcVelocity works out rolling averages over the days and the best and
+// worst 7 and 30 day runs. days and dates line up, nil days wrote nothing.
+// The first history days only feed the averages and are left out of the result.
+func CalcVelocity(days []*DailyUserStat, dates []time.Time, history int) Velocity {
+	words := make([]int, len(days))
+	for i, d := range days {
+		if d != nil {
+			words[i] = d.WordAdd
+		}
+	}
+
+	// Running total makes every window sum a subtraction
+	sums := make([]int, len(words)+1)
+	for i, w := range words {
+		sums[i+1] = sums[i] + w
+	}
+	windowSum := func(end int, n int) int {
+		start := end - n + 1
+		if start < 0 {
+			start = 0
+		}
+		return sums[end+1] - sums[start]
+	}
+
+	if history > len(days) {
+		history = len(days)
+	}
+
+	v := Velocity{}
+	for i := history; i < len(days); i++ {
+		v.Days = append(v.Days, VelocityDay{
+			Date:  dates[i].Format(shortDateFormat),
+			Words: words[i],
+			Avg7:  float64(windowSum(i, RollingShort)) / RollingShort,
+			Avg30: float64(windowSum(i, RollingLong)) / RollingLong,
+		})
+	}
+
+	last := len(days) - 1
+	if last < 0 {
+		return v
+	}
+
+	v.ThisWeek = windowSum(last, RollingShort)
+	if last-RollingShort >= 0 {
+		v.LastWeek = windowSum(last-RollingShort, RollingShort)
+	}
+	if v.LastWeek > 0 {
+		v.WeekChange = 100 * float64(v.ThisWeek-v.LastWeek) / float64(v.LastWeek)
+	}
+
+	v.BestWeek, v.WorstWeek = bestWorstRun(words, dates, history, RollingShort, windowSum)
+	v.BestMonth, v.WorstMonth = bestWorstRun(words, dates, history, RollingLong, windowSum)
+
+	return v
+}
+
+// bestWorstRun finds the highest and lowest n day windows that end after history
+func bestWorstRun(words []int, dates []time.Time, history int, n int, windowSum func(int, int) int) (best VelocityPeriod, worst VelocityPeriod) {
+	first := history
+	if first < n-1 {
+		first = n - 1
+	}
+
+	found := false
+	for end := first; end < len(words); end++ {
+		p := VelocityPeriod{
+			Start: dates[end-n+1].Format(shortDateFormat),
+			End:   dates[end].Format(shortDateFormat),
+			Words: windowSum(end, n),
+		}
+
+		if !found || p.Words > best.Words {
+			best = p
+		}
+		if !found || p.Words < worst.Words {
+			worst = p
+		}
+		found = true
+	}
+
+	return best, worst
+}
@@ -0,0 +1,120 @@
+package stat
+
+import (
+	"fmt"
+)
+
+// Window size in words for the moving average type-token ratio
+const MATTRWindow = 100
+
+type VocabStat struct {
+	Tokens int     `json:"Tokens"`
+	Types  int     `json:"Types"`
+	Hapax  int     `json:"Hapax"`
+	TTR    float64 `json:"TTR"`
+	MATTR  float64 `json:"MATTR"`
+}
+
+// VocabPeriod is the vocabulary of the last revision in a day or month
+type VocabPeriod struct {
+	Period string    `json:"Period"`
+	Vocab  VocabStat `json:"Vocab"`
+}
+
+func (v VocabStat) String() string {
+	return fmt.Sprintf("%d words %d unique %d hapax TTR %.3f MATTR %.3f", v.Tokens, v.Types, v.Hapax, v.TTR, v.MATTR)
+}
+
+// vocabFromMap works out the counts that only need the word map
+func vocabFromMap(wordMap map[string]int) VocabStat {
+	v := VocabStat{}
+	for k, c := range wordMap {
+		if k == "" {
+			continue
+		}
+		v.Tokens += c
+		v.Types++
+		if c == 1 {
+			v.Hapax++
+		}
+	}
+
+	if v.Tokens > 0 {
+		v.TTR = float64(v.Types) / float64(v.Tokens)
+	}
+	return v
+}
+
+// movingTTR averages the type-token ratio over a sliding window, which unlike
+// plain TTR does not fall just because the text got longer
+func movingTTR(words []string, window int) float64 {
+	if len(words) == 0 {
+		return 0
+	}
+	if len(words) <= window {
+		window = len(words)
+	}
+
+	counts := make(map[string]int)
+	types := 0
+	for _, w := range words[:window] {
+		if counts[w] == 0 {
+			types++
+		}
+		counts[w]++
+	}
+
+	total := float64(types)
+	steps := 1
+	for i := window; i < len(words); i++ {
+		out := words[i-window]
+		counts[out]--
+		if counts[out] == 0 {
+			types--
+		}
+
+		in := words[i]
+		if counts[in] == 0 {
+			types++
+		}
+		counts[in]++
+
+		total += float64(types)
+		steps++
+	}
+
+	return total / float64(steps) / float64(window)
+}
+
+// CalcVocab measures the lexical diversity of the text
+func CalcVocab(s string, mode string) VocabStat {
+	words := []string{}
+	for _, w := range normaliseWords(GetTokenizer(mode)(s)) {
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+
+	counts := make(map[string]int, len(words))
+	for _, w := range words {
+		counts[w]++
+	}
+
+	v := vocabFromMap(counts)
+	v.MATTR = movingTTR(words, MATTRWindow)
+	return v
+}
+
+// DocVocabByPeriod rolls revisions up by date prefix, 10 for days and 7 for months
+func DocVocabByPeriod(doc *DocStat, keyLen int) []VocabPeriod {
+	periods := []VocabPeriod{}
+	for _, rev := range doc.RevList {
+		key := rev.ModDate[:keyLen]
+		if len(periods) > 0 && periods[len(periods)-1].Period == key {
+			periods[len(periods)-1].Vocab = rev.Vocab
+		} else {
+			periods = append(periods, VocabPeriod{Period: key, Vocab: rev.Vocab})
+		}
+	}
+	return periods
+}
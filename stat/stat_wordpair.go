@@ -23,30 +23,50 @@ type WordPair struct {
 }
 
 func GetTopWords(s string) ([]WordPair, int) {
-	m, wc := wordCount(s)
+	return GetTopWordsMode(s, TokenModeDefault)
+}
+
+// GetTopWordsMode counts words using the named tokenizer
+func GetTopWordsMode(s string, mode string) ([]WordPair, int) {
+	m, wc := wordCountMode(s, mode)
 	return topWordPairFromMap(m, wc, 10, 3), wc
 }
 
+// GetTopContentWords is GetTopWords with stop words removed and, optionally, stemmed
+func GetTopContentWords(s string, set *Settings) []WordPair {
+	m, wc := wordCountMode(s, set.TokenMode)
+	stop := StopWordSet(set.StopLanguage, set.StopWords)
+	return topWordPairFromMap(contentWordMap(m, stop, set.Stemming), wc, 10, 3)
+}
+
 // WordCount returns a map of the counts of each “word” in the string s.
 func wordCount(s string) (map[string]int, int) {
+	return wordCountMode(s, TokenModeDefault)
+}
+
+func wordCountMode(s string, mode string) (map[string]int, int) {
+	words := normaliseWords(GetTokenizer(mode)(s))
 
-	f := func(c rune) bool {
-		return !unicode.IsLetter(c) && !unicode.IsNumber(c) && (c != '`') && (c != '\'')
+	counts := make(map[string]int, len(words))
+	for _, w := range words {
+		counts[w]++
 	}
 
+	return counts, len(words)
+}
+
+// normaliseWords lower cases words and trims anything but letters from the ends
+func normaliseWords(words []string) []string {
 	trimF := func(c rune) bool {
 		return !unicode.IsLetter(c)
 	}
 
-	words := strings.FieldsFunc(s, f)
-
-	counts := make(map[string]int, len(words))
-	for _, word := range words {
-		w := strings.ToLower(strings.TrimFunc(word, trimF))
-		counts[w]++
+	result := make([]string, len(words))
+	for i, word := range words {
+		result[i] = strings.ToLower(strings.TrimFunc(word, trimF))
 	}
 
-	return counts, len(words)
+	return result
 }
 
 func topWordPairFromMap(wordMap map[string]int, wordCount int, numResults int, minFreq int) (wList []WordPair) {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// SVG Line Charts
+//
+type svgSeries struct {
+	Name      string
+	Classname string
+	Values    []float64
+}
+
+type svgLine struct {
+	Name      string
+	Classname string
+	Points    string
+}
+
+type svgLineChart struct {
+	Width  int
+	Height int
+	MinVal float64
+	MaxVal float64
+	Lines  []svgLine
+}
+
+// makeLineChart scales all series to share one y axis, x is the value index
+func makeLineChart(width, height int, series ...svgSeries) *svgLineChart {
+	chart := &svgLineChart{Width: width, Height: height}
+
+	first := true
+	maxLen := 0
+	for _, s := range series {
+		for _, v := range s.Values {
+			if first || v < chart.MinVal {
+				chart.MinVal = v
+			}
+			if first || v > chart.MaxVal {
+				chart.MaxVal = v
+			}
+			first = false
+		}
+		if len(s.Values) > maxLen {
+			maxLen = len(s.Values)
+		}
+	}
+
+	if first {
+		return nil
+	}
+
+	valRange := chart.MaxVal - chart.MinVal
+	if valRange == 0 {
+		valRange = 1
+	}
+
+	xStep := float64(width)
+	if maxLen > 1 {
+		xStep = float64(width) / float64(maxLen-1)
+	}
+
+	for _, s := range series {
+		points := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			y := float64(height) - float64(height)*(v-chart.MinVal)/valRange
+			points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*xStep, y)
+		}
+
+		chart.Lines = append(chart.Lines, svgLine{
+			Name:      s.Name,
+			Classname: s.Classname,
+			Points:    strings.Join(points, " "),
+		})
+	}
+
+	return chart
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"GoDriveTracker/database"
+	"GoDriveTracker/stat"
+)
+
+// Days in each period when comparing without dates
+const driftDefaultDays = 30
+
+// styleDrift compares two documents given their ids, two date ranges given
+// as fromA toA fromB toB, or with no args the last 30 days against the 30 before
+func styleDrift(db *database.StatTrackerDB, args []string) (*stat.DriftReport, error) {
+	switch len(args) {
+	case 0:
+		today := time.Now()
+		args = []string{
+			today.AddDate(0, 0, 1-2*driftDefaultDays).Format(dateFormat),
+			today.AddDate(0, 0, -driftDefaultDays).Format(dateFormat),
+			today.AddDate(0, 0, 1-driftDefaultDays).Format(dateFormat),
+			today.Format(dateFormat),
+		}
+		fallthrough
+
+	case 4:
+		for _, a := range args {
+			if _, err := time.Parse(dateFormat, a); err != nil {
+				return nil, fmt.Errorf("Invalid date %s", a)
+			}
+		}
+
+		docs := []*stat.DocStat{}
+		for f := db.LoadNextFileStat(""); f != nil; f = db.LoadNextFileStat(f.FileId) {
+			docs = append(docs, f)
+		}
+
+		text := storedText(db)
+		report := stat.CompareProfiles(stat.ProfileRange(docs, args[0], args[1], text), stat.ProfileRange(docs, args[2], args[3], text))
+		return &report, nil
+
+	case 2:
+		docA := db.LoadFileStats(args[0])
+		docB := db.LoadFileStats(args[1])
+		if docA == nil || docB == nil {
+			return nil, fmt.Errorf("No stats for %s or %s", args[0], args[1])
+		}
+
+		text := storedText(db)
+		report := stat.CompareProfiles(stat.ProfileDoc(docA, text), stat.ProfileDoc(docB, text))
+		return &report, nil
+	}
+
+	return nil, errors.New("Compare two file ids or four dates: fromA toA fromB toB")
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Drift Handle
+type DriftHandle struct {
+	db *database.StatTrackerDB
+}
+
+func (dh DriftHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	args := []string{}
+	if req.FormValue("DocA") != "" || req.FormValue("DocB") != "" {
+		args = []string{req.FormValue("DocA"), req.FormValue("DocB")}
+	} else if req.FormValue("FromA") != "" {
+		args = []string{req.FormValue("FromA"), req.FormValue("ToA"), req.FormValue("FromB"), req.FormValue("ToB")}
+	}
+
+	report, err := styleDrift(dh.db, args)
+	if err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+
+	docs := []*stat.DocStat{}
+	for f := dh.db.LoadNextFileStat(""); f != nil; f = dh.db.LoadNextFileStat(f.FileId) {
+		docs = append(docs, f)
+	}
+
+	driftTemp, err := template.ParseFiles("./templates/drift.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	e := driftTemp.Execute(rw, struct {
+		Report *stat.DriftReport
+		Args   []string
+		Docs   []*stat.DocStat
+	}{
+		report,
+		args,
+		docs,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"encoding/csv"
 	"fmt"
 	"html/template"
 	"log"
 	"math"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"GoDriveTracker/database"
@@ -18,6 +21,11 @@ import (
 var (
 	reDayPathMatch  = regexp.MustCompile("/day/([0-9]+)[/\\-]([0-9]+)[/\\-]([0-9]+)")
 	reFilePathMatch = regexp.MustCompile("/file/([^/]+)")
+	reFileTarget    = regexp.MustCompile("/file/([^/]+)/target")
+	reFileImport    = regexp.MustCompile("/file/([^/]+)/import")
+	reFileExport    = regexp.MustCompile("/file/([^/]+)/export.csv")
+	reFileSections  = regexp.MustCompile("/file/([^/]+)/sections.csv")
+	reFileBlame     = regexp.MustCompile("/file/([^/]+)/blame")
 )
 
 const (
@@ -31,6 +39,16 @@ func SetupWebFace(wf *web.WebFace, dbPtr *database.StatTrackerDB) {
 	wf.Router.Handle("/", sh)
 	wf.Router.Handle("/day/", DayHandle{db: dbPtr})
 	wf.Router.Handle("/file/", FileHandle{db: dbPtr})
+	wf.Router.Handle("/settings/", SettingsHandle{db: dbPtr})
+	wf.Router.Handle("/projects/", ProjectListHandle{db: dbPtr})
+	wf.Router.Handle("/project/", ProjectHandle{db: dbPtr})
+	wf.Router.Handle("/tags/", TagsHandle{db: dbPtr})
+	wf.Router.Handle("/drift/", DriftHandle{db: dbPtr})
+	wf.Router.Handle("/cuts/", CutsHandle{db: dbPtr})
+	wf.Router.Handle("/heatmap.png", HeatmapHandle{db: dbPtr})
+	for _, kind := range stat.RollupKinds {
+		wf.Router.Handle("/"+kind+"/", PeriodHandle{db: dbPtr, kind: kind})
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -49,6 +67,46 @@ type gPoint struct {
 	Boxes []svgBox
 }
 
+type heatCell struct {
+	X, Y    int
+	Opacity float64
+	Words   int
+	Label   string
+}
+
+type editCell struct {
+	X, W    float64
+	Y, H    int
+	Opacity float64
+	Label   string
+}
+
+type svgLabel struct {
+	X, Y int
+	Text string
+}
+
+type editMapView struct {
+	Stat   stat.EditMap
+	Width  int
+	Height int
+	PlotX  int
+	PlotW  int
+	PlotY  int
+	Cells  []editCell
+	Labels []svgLabel
+}
+
+type heatmapView struct {
+	Stat      stat.Heatmap
+	CellSize  int
+	Width     int
+	Height    int
+	Cells     []heatCell
+	DayLabels []svgBox
+	Hours     []svgBox
+}
+
 type SummaryHandle struct {
 	db           *database.StatTrackerDB
 	DayList      map[int]map[time.Month]map[int]*stat.DailyUserStat
@@ -57,6 +115,10 @@ type SummaryHandle struct {
 	GridDayLines []int
 	GridWidth    int
 	GridHeight   int
+	Heatmap      *heatmapView
+	Velocity     stat.Velocity
+	Avg7Line     string
+	Avg30Line    string
 }
 
 func (sh *SummaryHandle) Setup() {
@@ -116,16 +178,35 @@ func (sh *SummaryHandle) Setup() {
 		}
 	}
 
-	newDate = firstDay
-	dateList := make([]time.Time, 100)
-	dayList := make([]*stat.DailyUserStat, 100)
-	for i := 0; i < 100; i += 1 {
+	// Extra days before the graph so the rolling averages start full
+	history := stat.RollingLong
+	newDate = firstDay.AddDate(0, 0, -history)
+	dateList := make([]time.Time, 100+history)
+	dayList := make([]*stat.DailyUserStat, 100+history)
+	for i := 0; i < 100+history; i += 1 {
 		dateList[i] = newDate
 		dayList[i] = sh.GetDayListDay(newDate)
 		newDate = newDate.AddDate(0, 0, 1)
 	}
 
-	dailyWordHistChart("./static/days.png", 700, 400, dayList, dateList)
+	sh.Velocity = stat.CalcVelocity(dayList, dateList, history)
+	dateList = dateList[history:]
+	dayList = dayList[history:]
+
+	avg7 := make([]float64, len(sh.Velocity.Days))
+	avg30 := make([]float64, len(sh.Velocity.Days))
+	sh.Avg7Line = ""
+	sh.Avg30Line = ""
+	for i, v := range sh.Velocity.Days {
+		avg7[i] = v.Avg7
+		avg30[i] = v.Avg30
+
+		x := i*XStep + XStep/2
+		sh.Avg7Line += fmt.Sprintf("%d,%d ", x, sh.GridHeight-int(math.Pow(v.Avg7, 0.7)))
+		sh.Avg30Line += fmt.Sprintf("%d,%d ", x, sh.GridHeight-int(math.Pow(v.Avg30, 0.7)))
+	}
+
+	dailyWordHistChart("./static/days.png", 700, 400, dayList, dateList, avg7, avg30)
 
 	for i, day := range dateList {
 		d := sh.GetDayListDay(day)
@@ -211,11 +292,87 @@ func (sh *SummaryHandle) SetDayListDay(dateKey time.Time, data *stat.DailyUserSt
 	month[dateKey.Day()] = data
 }
 
+func makeHeatmapView(hm stat.Heatmap) *heatmapView {
+	view := &heatmapView{Stat: hm, CellSize: 30}
+	labelW := 40
+	view.Width = labelW + 24*view.CellSize
+	view.Height = view.CellSize + 7*view.CellSize
+
+	for hour := 0; hour < 24; hour += 1 {
+		view.Hours = append(view.Hours, svgBox{X: labelW + hour*view.CellSize, Y: view.CellSize - 10, Classname: fmt.Sprintf("%02d", hour)})
+	}
+
+	// Monday first like the calendar
+	for row := 0; row < 7; row += 1 {
+		day := time.Weekday((row + 1) % 7)
+		y := view.CellSize + row*view.CellSize
+		view.DayLabels = append(view.DayLabels, svgBox{X: 0, Y: y + view.CellSize/2, Classname: day.String()[:3]})
+
+		for hour := 0; hour < 24; hour += 1 {
+			words := hm.Words[day][hour]
+			cell := heatCell{
+				X:     labelW + hour*view.CellSize,
+				Y:     y,
+				Words: words,
+				Label: fmt.Sprintf("%s %02d:00 %d words", day, hour, words),
+			}
+			if hm.Max > 0 {
+				cell.Opacity = float64(words) / float64(hm.Max)
+			}
+			view.Cells = append(view.Cells, cell)
+		}
+	}
+
+	return view
+}
+
+// calcHeatmap works out the heatmap for the ?from=&to= range, defaulting to
+// the last 90 days
+func calcHeatmap(db *database.StatTrackerDB, req *http.Request) stat.Heatmap {
+	loc := currentSettings().Location()
+	now := time.Now().In(loc)
+
+	to, errTo := time.ParseInLocation(dateFormat, req.FormValue("to"), loc)
+	if errTo != nil {
+		to = now
+	}
+	from, errFrom := time.ParseInLocation(dateFormat, req.FormValue("from"), loc)
+	if errFrom != nil {
+		from = to.AddDate(0, 0, -90)
+	}
+
+	docs := []*stat.DocStat{}
+	for f := db.LoadNextFileStat(""); f != nil; f = db.LoadNextFileStat(f.FileId) {
+		docs = append(docs, f)
+	}
+
+	return stat.CalcHeatmap(docs, from, to, loc)
+}
+
+func (sh *SummaryHandle) serveHeatmap(req *http.Request) {
+	sh.Heatmap = makeHeatmapView(calcHeatmap(sh.db, req))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Heatmap Handle, the PNG version of the summary heatmap
+type HeatmapHandle struct {
+	db *database.StatTrackerDB
+}
+
+func (hh HeatmapHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "image/png")
+	if err := heatmapChart(rw, 740, 220, calcHeatmap(hh.db, req)); err != nil {
+		log.Println("Error drawing heatmap", err)
+	}
+}
+
 func (sh SummaryHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	sumTemp, err := template.ParseFiles("./templates/summary.html")
 	if err != nil {
 		log.Fatalln("Error parsing:", err)
 	}
+
+	sh.serveHeatmap(req)
 	e := sumTemp.Execute(rw, sh)
 	if e != nil {
 		log.Println("Error in Temp", e)
@@ -229,11 +386,13 @@ type DayHandle struct {
 }
 
 type DayData struct {
-	FullDate  string
-	Stat      *stat.DailyUserStat
-	WordTotal int
-	DocList   []*stat.DocStat
-	RevList   []*stat.RevStat
+	FullDate    string
+	Stat        *stat.DailyUserStat
+	WordTotal   int
+	DocList     []*stat.DocStat
+	RevList     []*stat.RevStat
+	ProjectDays []stat.ProjectDay
+	Settings    *stat.Settings
 }
 
 func (dh DayHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -295,6 +454,9 @@ func (dh DayHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		WordTotal: dayStat.WordAdd + dayStat.WordSub,
 		DocList:   dList,
 		RevList:   rList,
+
+		ProjectDays: stat.DayByProject(dayStat, fileProjects(dh.db)),
+		Settings:    currentSettings(),
 	})
 	if e != nil {
 		log.Println("Error in Temp", e)
@@ -308,8 +470,155 @@ type FileHandle struct {
 	db *database.StatTrackerDB
 }
 
+type burnUpChart struct {
+	Width     int
+	Height    int
+	Actual    string
+	Ideal     string
+	Projected string
+	TargetY   int
+	DeadlineX int
+	TodayX    int
+}
+
+func makeBurnUpChart(tp stat.TargetProjection, now time.Time) *burnUpChart {
+	if len(tp.Points) == 0 {
+		return nil
+	}
+
+	chart := &burnUpChart{Width: 800, Height: 300}
+
+	startDate, sErr := time.Parse(dateFormat, tp.Points[0].Date)
+	if sErr != nil {
+		return nil
+	}
+
+	endDate := now
+	deadline, dErr := time.Parse(dateFormat, tp.Target.Deadline)
+	if dErr == nil && deadline.After(endDate) {
+		endDate = deadline
+	}
+
+	// Slow projections can be decades out, only stretch the chart so far
+	maxEnd := endDate.Add(endDate.Sub(startDate))
+	projected, pErr := time.Parse(dateFormat, tp.ProjectedDate)
+	if pErr == nil && projected.After(endDate) {
+		endDate = projected
+		if endDate.After(maxEnd) {
+			endDate = maxEnd
+		}
+	}
+
+	maxWords := tp.Target.WordTarget
+	if tp.CurrentWords > maxWords {
+		maxWords = tp.CurrentWords
+	}
+	maxWords = maxWords + maxWords/10 + 1
+
+	totalDays := endDate.Sub(startDate).Hours()/24 + 1
+	xPos := func(d time.Time) int {
+		return int(float64(chart.Width) * d.Sub(startDate).Hours() / 24 / totalDays)
+	}
+	yPos := func(words int) int {
+		return chart.Height - chart.Height*words/maxWords
+	}
+
+	points := []string{}
+	for _, p := range tp.Points {
+		d, e := time.Parse(dateFormat, p.Date)
+		if e != nil {
+			continue
+		}
+		points = append(points, fmt.Sprintf("%d,%d", xPos(d), yPos(p.Words)))
+	}
+	chart.Actual = strings.Join(points, " ")
+
+	chart.TargetY = yPos(tp.Target.WordTarget)
+	chart.TodayX = xPos(now)
+
+	if dErr == nil {
+		chart.DeadlineX = xPos(deadline)
+		chart.Ideal = fmt.Sprintf("0,%d %d,%d", yPos(tp.StartWords), chart.DeadlineX, chart.TargetY)
+	}
+	if pErr == nil {
+		projX, projY := xPos(projected), chart.TargetY
+		if projected.After(endDate) {
+			daysToEnd := endDate.Sub(now).Hours() / 24
+			projX, projY = chart.Width, yPos(tp.CurrentWords+int(tp.TrailingPace*daysToEnd))
+		}
+		chart.Projected = fmt.Sprintf("%d,%d %d,%d", chart.TodayX, yPos(tp.CurrentWords), projX, projY)
+	}
+
+	return chart
+}
+
+// makeEditMapView lays out the edit map with days across and the document
+// from start at the top to end at the bottom
+func makeEditMapView(em stat.EditMap) *editMapView {
+	if len(em.Days) == 0 || em.Total == 0 {
+		return nil
+	}
+
+	labelW := 40
+	bandH := 10
+	view := &editMapView{Stat: em, Width: 800, PlotX: labelW, PlotW: 800 - labelW, PlotY: stat.EditMapBands * bandH}
+	view.Height = view.PlotY + 16
+	dayW := float64(view.PlotW) / float64(len(em.Days))
+
+	view.Labels = []svgLabel{
+		{X: 0, Y: 10, Text: "start"},
+		{X: 0, Y: view.PlotY, Text: "end"},
+		{X: labelW, Y: view.Height - 2, Text: em.From},
+		{X: view.Width - 70, Y: view.Height - 2, Text: em.To},
+	}
+
+	for day, bands := range em.Words {
+		for band, words := range bands {
+			if words == 0 {
+				continue
+			}
+			view.Cells = append(view.Cells, editCell{
+				X:       float64(labelW) + float64(day)*dayW,
+				W:       dayW,
+				Y:       band * bandH,
+				H:       bandH,
+				Opacity: float64(words) / float64(em.Max),
+				Label: fmt.Sprintf("%s %d-%d%% %d words", em.Days[day],
+					band*100/stat.EditMapBands, (band+1)*100/stat.EditMapBands, words),
+			})
+		}
+	}
+
+	return view
+}
+
 func (dh FileHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
+	if targetMatch := reFileTarget.FindStringSubmatch(req.URL.Path); targetMatch != nil {
+		dh.serveTarget(rw, req, targetMatch[1])
+		return
+	}
+
+	if importMatch := reFileImport.FindStringSubmatch(req.URL.Path); importMatch != nil {
+		dh.serveImport(rw, req, importMatch[1])
+		return
+	}
+
+	if exportMatch := reFileExport.FindStringSubmatch(req.URL.Path); exportMatch != nil {
+		dh.serveExport(rw, req, exportMatch[1])
+		return
+	}
+
+	if sectionsMatch := reFileSections.FindStringSubmatch(req.URL.Path); sectionsMatch != nil {
+		dh.serveSectionsExport(rw, req, sectionsMatch[1])
+		return
+	}
+
+	if blameMatch := reFileBlame.FindStringSubmatch(req.URL.Path); blameMatch != nil {
+		dh.serveBlame(rw, req, blameMatch[1])
+		return
+	}
+
 	fileTemp, err := template.ParseFiles("./templates/fileStat.html")
 	if err != nil {
 		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
@@ -335,14 +644,131 @@ func (dh FileHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Projection is worked out from the stored revisions so it is current after every sync
+	var projection *stat.TargetProjection
+	var burnUp *burnUpChart
+	if target := dh.db.LoadTarget(fileStat.FileId); target != nil {
+		tp := stat.ProjectTarget(*target, stat.DocWordPoints(fileStat), time.Now())
+		projection = &tp
+		burnUp = makeBurnUpChart(tp, time.Now())
+	}
+
+	ease := []float64{}
+	grade := []float64{}
+	for _, r := range fileStat.RevList {
+		ease = append(ease, r.Readability.FleschEase)
+		grade = append(grade, r.Readability.FleschKincaid)
+	}
+	easeChart := makeLineChart(800, 200, svgSeries{Name: "Reading Ease", Classname: "easeLine", Values: ease})
+	gradeChart := makeLineChart(800, 200, svgSeries{Name: "Grade Level", Classname: "gradeLine", Values: grade})
+
+	vocabDays := stat.DocVocabByPeriod(fileStat, 10)
+	ttr := []float64{}
+	mattr := []float64{}
+	vocabSize := []float64{}
+	for _, v := range vocabDays {
+		ttr = append(ttr, v.Vocab.TTR)
+		mattr = append(mattr, v.Vocab.MATTR)
+		vocabSize = append(vocabSize, float64(v.Vocab.Types))
+	}
+	diversityChart := makeLineChart(800, 200,
+		svgSeries{Name: "Type-Token Ratio", Classname: "ttrLine", Values: ttr},
+		svgSeries{Name: "Moving Average TTR", Classname: "mattrLine", Values: mattr})
+	vocabChart := makeLineChart(800, 200, svgSeries{Name: "Vocabulary Size", Classname: "vocabLine", Values: vocabSize})
+
+	fillerRate := []float64{}
+	echoRate := []float64{}
+	for _, r := range fileStat.RevList {
+		if r.WordCount > 0 {
+			fillerRate = append(fillerRate, 1000*float64(r.Fillers.Total)/float64(r.WordCount))
+			echoRate = append(echoRate, 1000*float64(r.Fillers.Echoes)/float64(r.WordCount))
+		}
+	}
+	fillerChart := makeLineChart(800, 200,
+		svgSeries{Name: "Fillers per 1000 words", Classname: "fillerLine", Values: fillerRate},
+		svgSeries{Name: "Echoes per 1000 words", Classname: "echoLine", Values: echoRate})
+
+	dialogue := []float64{}
+	paraLength := []float64{}
+	for _, r := range fileStat.RevList {
+		if r.Prose.Paragraphs > 0 {
+			dialogue = append(dialogue, 100*r.Prose.DialogueShare())
+			paraLength = append(paraLength, r.Prose.AvgParagraph)
+		}
+	}
+	dialogueChart := makeLineChart(800, 200, svgSeries{Name: "Dialogue %", Classname: "dialogueLine", Values: dialogue})
+	paragraphChart := makeLineChart(800, 200, svgSeries{Name: "Words per paragraph", Classname: "paragraphLine", Values: paraLength})
+
+	parents := []string{}
+	if file := dh.db.LoadFile(fileStat.FileId); file != nil {
+		parents = fileParents(file)
+	}
+	glossary := fileGlossary(dh.db, fileStat.FileId, parents)
+
+	var latestSections []stat.SectionStat
+	var latestProse stat.ProseStat
+	if len(fileStat.RevList) > 0 {
+		latestSections = fileStat.RevList[len(fileStat.RevList)-1].Sections
+		latestProse = fileStat.RevList[len(fileStat.RevList)-1].Prose
+	}
+
 	e := fileTemp.Execute(rw, struct {
-		FullDate string
-		ModDate  string
-		Stat     *stat.DocStat
+		FullDate   string
+		ModDate    string
+		Stat       *stat.DocStat
+		Projection *stat.TargetProjection
+		BurnUp     *burnUpChart
+		EaseChart  *svgLineChart
+		GradeChart *svgLineChart
+		Phrases    []stat.PhraseTrend
+
+		DiversityChart *svgLineChart
+		VocabChart     *svgLineChart
+		VocabMonths    []stat.VocabPeriod
+
+		Sections       []stat.SectionStat
+		SectionChanges []stat.SectionDay
+
+		Keywords []stat.TermScore
+		Related  []stat.RelatedDoc
+
+		FillerChart *svgLineChart
+		Fillers     []stat.PhraseTrend
+		Echoes      []stat.PhraseTrend
+
+		Entities []entityChart
+
+		EditMap *editMapView
+
+		Prose          stat.ProseStat
+		DialogueChart  *svgLineChart
+		ParagraphChart *svgLineChart
+		ParagraphHist  []paragraphBucket
 	}{
 		date.Format("Monday, 2 Jan 2006"),
 		date.Format(dateFormat),
 		fileStat,
+		projection,
+		burnUp,
+		easeChart,
+		gradeChart,
+		stat.DocPhraseTrends(fileStat),
+		diversityChart,
+		vocabChart,
+		stat.DocVocabByPeriod(fileStat, 7),
+		latestSections,
+		stat.DocSectionChanges(fileStat),
+		dh.db.LoadCorpus().Distinctive(dh.db.LoadDocTerms(fileStat.FileId), stat.DistinctiveTerms),
+		stat.RelatedDocs(fileStat.Print(), dh.db.LoadPrints()),
+		fillerChart,
+		stat.DocFillerTrends(fileStat),
+		stat.DocEchoTrends(fileStat),
+		entityCharts(stat.DocEntitySeries(fileStat, glossary)),
+		makeEditMapView(stat.DocEditMap(fileStat)),
+		latestProse,
+		dialogueChart,
+		paragraphChart,
+		paragraphBuckets(latestProse),
 	})
 
 	if e != nil {
@@ -350,3 +776,550 @@ func (dh FileHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 }
+
+type paragraphBucket struct {
+	Label string
+	Count int
+}
+
+// paragraphBuckets pairs the paragraph length histogram with its labels
+func paragraphBuckets(ps stat.ProseStat) []paragraphBucket {
+	buckets := []paragraphBucket{}
+	for i, label := range stat.ParagraphHistLabels() {
+		if i < len(ps.ParagraphHist) {
+			buckets = append(buckets, paragraphBucket{Label: label, Count: ps.ParagraphHist[i]})
+		}
+	}
+	return buckets
+}
+
+// targetFromForm reads the word target and deadline of a target form
+func targetFromForm(req *http.Request, id string) (*stat.Target, error) {
+	wordTarget, errTarget := strconv.Atoi(req.FormValue("WordTarget"))
+	if errTarget != nil {
+		return nil, fmt.Errorf("Invalid word target: %s", errTarget)
+	}
+
+	deadline := req.FormValue("Deadline")
+	if _, errDate := time.Parse(dateFormat, deadline); errDate != nil {
+		return nil, fmt.Errorf("Invalid deadline: %s", errDate)
+	}
+
+	return &stat.Target{
+		Id:         id,
+		WordTarget: wordTarget,
+		Deadline:   deadline,
+	}, nil
+}
+
+// serveTarget sets the word target and deadline from the file page form
+func (dh FileHandle) serveTarget(rw http.ResponseWriter, req *http.Request, fileId string) {
+	if req.Method != "POST" {
+		http.Error(rw, "Target must be POST", 405)
+		return
+	}
+
+	target, err := targetFromForm(req, fileId)
+	if err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+
+	dh.db.WriteTarget(target)
+
+	http.Redirect(rw, req, "/file/"+fileId, 303)
+}
+
+// serveImport lets the user overrule the pasted text detection of a revision
+func (dh FileHandle) serveImport(rw http.ResponseWriter, req *http.Request, fileId string) {
+	if req.Method != "POST" {
+		http.Error(rw, "Import must be POST", 405)
+		return
+	}
+
+	override := req.FormValue("Override")
+	if override != stat.ImportOverrideNone && override != stat.ImportOverrideImported && override != stat.ImportOverrideCounted {
+		http.Error(rw, fmt.Sprintf("Invalid override: %s", override), 400)
+		return
+	}
+
+	fileStat := dh.db.LoadFileStats(fileId)
+	if fileStat == nil {
+		http.Error(rw, fmt.Sprintf("No stats for %s", fileId), 404)
+		return
+	}
+
+	prev := dh.db.LoadFileStats(fileId)
+
+	revId := req.FormValue("RevId")
+	found := false
+	for i := range fileStat.RevList {
+		if fileStat.RevList[i].RevId == revId {
+			fileStat.RevList[i].ImportOverride = override
+			found = true
+		}
+	}
+	if !found {
+		http.Error(rw, fmt.Sprintf("No revision %s", revId), 404)
+		return
+	}
+
+	dh.db.WriteFileStats(fileStat)
+	UpdateDailyStats(dh.db, prev, fileStat)
+
+	http.Redirect(rw, req, "/file/"+fileId, 303)
+}
+
+// serveExport writes the per revision stats of a file as CSV
+func (dh FileHandle) serveExport(rw http.ResponseWriter, req *http.Request, fileId string) {
+	fileStat := dh.db.LoadFileStats(fileId)
+	if fileStat == nil {
+		http.Error(rw, fmt.Sprintf("No stats for %s", fileId), 404)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", fileId))
+
+	w := csv.NewWriter(rw)
+	header := []string{"RevId", "ModDate", "UserName", "Words", "Chars", "Pages", "ReadMinutes",
+		"Imported", "TokensInserted", "TokensDeleted", "Churn", "FleschEase", "FleschKincaid",
+		"Fillers", "Echoes", "DialogueWords", "NarrationWords", "DialogueShare", "DialogueRatio",
+		"Paragraphs", "DialogueParagraphs", "AvgParagraph", "LongestParagraph"}
+	for _, label := range stat.ParagraphHistLabels() {
+		header = append(header, "Paragraphs "+label)
+	}
+	w.Write(header)
+
+	for _, r := range fileStat.RevList {
+		row := []string{r.RevId, r.ModDate, r.UserName,
+			strconv.Itoa(r.WordCount), strconv.Itoa(r.Chars),
+			fmt.Sprintf("%.2f", r.Pages), fmt.Sprintf("%.1f", r.ReadMinutes),
+			strconv.FormatBool(r.IsImported()),
+			strconv.Itoa(r.TokensInserted), strconv.Itoa(r.TokensDeleted), fmt.Sprintf("%.3f", r.Churn),
+			fmt.Sprintf("%.1f", r.Readability.FleschEase), fmt.Sprintf("%.1f", r.Readability.FleschKincaid),
+			strconv.Itoa(r.Fillers.Total), strconv.Itoa(r.Fillers.Echoes),
+			strconv.Itoa(r.Prose.DialogueWords), strconv.Itoa(r.Prose.NarrationWords),
+			fmt.Sprintf("%.3f", r.Prose.DialogueShare()), fmt.Sprintf("%.3f", r.Prose.DialogueRatio()),
+			strconv.Itoa(r.Prose.Paragraphs), strconv.Itoa(r.Prose.DialogueParagraphs),
+			fmt.Sprintf("%.1f", r.Prose.AvgParagraph), strconv.Itoa(r.Prose.LongestParagraph)}
+		for i := 0; i < stat.ParagraphHistBuckets; i++ {
+			count := 0
+			if i < len(r.Prose.ParagraphHist) {
+				count = r.Prose.ParagraphHist[i]
+			}
+			row = append(row, strconv.Itoa(count))
+		}
+		w.Write(row)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Println("Error writing export", err)
+	}
+}
+
+// serveSectionsExport writes the sections of the latest revision as CSV
+func (dh FileHandle) serveSectionsExport(rw http.ResponseWriter, req *http.Request, fileId string) {
+	fileStat := dh.db.LoadFileStats(fileId)
+	if fileStat == nil {
+		http.Error(rw, fmt.Sprintf("No stats for %s", fileId), 404)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-sections.csv\"", fileId))
+
+	w := csv.NewWriter(rw)
+	w.Write([]string{"Path", "Level", "Words", "TotalWords", "DialogueWords", "DialogueShare"})
+	if len(fileStat.RevList) > 0 {
+		for _, sec := range fileStat.RevList[len(fileStat.RevList)-1].Sections {
+			w.Write([]string{sec.Path, strconv.Itoa(sec.Level), strconv.Itoa(sec.Words), strconv.Itoa(sec.TotalWords),
+				strconv.Itoa(sec.DialogueWords), fmt.Sprintf("%.3f", sec.DialogueShare())})
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Println("Error writing export", err)
+	}
+}
+
+type blameParagraph struct {
+	stat.ParagraphBlame
+	Age      int
+	FirstDay string
+	LastDay  string
+}
+
+type blameAge struct {
+	Age   int
+	Label string
+	Count int
+}
+
+// serveBlame shows each paragraph of the latest revision coloured by the age
+// of its last change, or of when it was written with ?by=first
+func (dh FileHandle) serveBlame(rw http.ResponseWriter, req *http.Request, fileId string) {
+	blameTemp, err := template.ParseFiles("./templates/blame.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	fileStat := dh.db.LoadFileStats(fileId)
+	if fileStat == nil {
+		http.Error(rw, fmt.Sprintf("No stats for %s", fileId), 404)
+		return
+	}
+
+	byFirst := req.FormValue("by") == "first"
+	now := time.Now()
+
+	ages := []blameAge{}
+	for i, label := range stat.BlameAgeLabels() {
+		ages = append(ages, blameAge{Age: i, Label: label})
+	}
+
+	paragraphs := []blameParagraph{}
+	blame := dh.db.LoadBlame(fileId)
+	if blame != nil {
+		for _, pb := range blame.Paragraphs {
+			date := pb.LastDate
+			if byFirst {
+				date = pb.FirstDate
+			}
+			bp := blameParagraph{ParagraphBlame: pb, Age: stat.BlameAge(date, now)}
+			if len(pb.FirstDate) >= 10 && len(pb.LastDate) >= 10 {
+				bp.FirstDay = pb.FirstDate[:10]
+				bp.LastDay = pb.LastDate[:10]
+			}
+			ages[bp.Age].Count++
+			paragraphs = append(paragraphs, bp)
+		}
+	}
+
+	e := blameTemp.Execute(rw, struct {
+		Stat       *stat.DocStat
+		ByFirst    bool
+		Blame      *stat.DocBlame
+		Paragraphs []blameParagraph
+		Ages       []blameAge
+	}{
+		fileStat,
+		byFirst,
+		blame,
+		paragraphs,
+		ages,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Period Handle
+type PeriodHandle struct {
+	db   *database.StatTrackerDB
+	kind string
+}
+
+type periodDay struct {
+	Date string
+	Day  stat.RollupDay
+}
+
+type periodLink struct {
+	Kind   string
+	Period string
+}
+
+func (ph PeriodHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	period := strings.Trim(strings.TrimPrefix(req.URL.Path, "/"+ph.kind), "/")
+	if period == "" {
+		period = stat.PeriodKey(ph.kind, time.Now())
+	}
+
+	start, errPeriod := stat.PeriodStart(ph.kind, period)
+	if errPeriod != nil {
+		http.Error(rw, fmt.Sprintf("Invalid period: %s", errPeriod), 400)
+		return
+	}
+	end := stat.PeriodEnd(ph.kind, start).AddDate(0, 0, -1)
+
+	rollup := ph.db.LoadRollup(ph.kind, period)
+	if rollup == nil {
+		rollup = stat.NewRollupStat(ph.kind, period)
+	}
+
+	links := []periodLink{}
+	for _, kind := range stat.RollupKinds {
+		links = append(links, periodLink{Kind: kind, Period: stat.PeriodKey(kind, start)})
+	}
+
+	days := []periodDay{}
+	for _, d := range rollup.SortedDays() {
+		days = append(days, periodDay{Date: d, Day: rollup.Days[d]})
+	}
+
+	periodTemp, err := template.ParseFiles("./templates/period.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	e := periodTemp.Execute(rw, struct {
+		Kind     string
+		Start    string
+		End      string
+		Prev     string
+		Next     string
+		Stat     *stat.RollupStat
+		Days     []periodDay
+		Links    []periodLink
+		Settings *stat.Settings
+	}{
+		ph.kind,
+		start.Format("Monday, 2 Jan 2006"),
+		end.Format("Monday, 2 Jan 2006"),
+		stat.ShiftPeriod(ph.kind, period, -1),
+		stat.ShiftPeriod(ph.kind, period, 1),
+		rollup,
+		days,
+		links,
+		currentSettings(),
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Tags Handle
+type TagsHandle struct {
+	db *database.StatTrackerDB
+}
+
+func (th TagsHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	query := strings.TrimSpace(req.FormValue("q"))
+	corpus := th.db.LoadCorpus()
+	tags := corpus.TagCloud(th.db.LoadAllDocTerms(), query)
+
+	// A single tag lists the documents it is distinctive in
+	var selected *stat.Tag
+	if tagWord := req.FormValue("tag"); tagWord != "" {
+		for i := range tags {
+			if tags[i].Word == tagWord {
+				selected = &tags[i]
+			}
+		}
+	}
+
+	tagTemp, err := template.ParseFiles("./templates/tags.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	e := tagTemp.Execute(rw, struct {
+		Query    string
+		Docs     int
+		Tags     []stat.Tag
+		Selected *stat.Tag
+	}{
+		query,
+		corpus.Docs,
+		tags,
+		selected,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Cuts Handle
+type CutsHandle struct {
+	db *database.StatTrackerDB
+}
+
+// loadCuts returns the stored cuts matching the search, newest first,
+// limited to one file if fileId is set
+func (ch CutsHandle) loadCuts(query string, fileId string) []stat.CutPassage {
+	cuts := []stat.CutPassage{}
+	for _, cut := range stat.SearchCuts(ch.db.LoadCuts(), query) {
+		if fileId == "" || cut.FileId == fileId {
+			cuts = append(cuts, cut)
+		}
+	}
+	sort.Stable(stat.CutsByDate(cuts))
+	return cuts
+}
+
+func (ch CutsHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	query := strings.TrimSpace(req.FormValue("q"))
+	fileId := req.FormValue("file")
+	cuts := ch.loadCuts(query, fileId)
+
+	if strings.HasSuffix(req.URL.Path, "/export.txt") {
+		ch.serveExport(rw, cuts)
+		return
+	}
+
+	cutTemp, err := template.ParseFiles("./templates/cuts.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	e := cutTemp.Execute(rw, struct {
+		Query  string
+		FileId string
+		Cuts   []stat.CutPassage
+	}{
+		query,
+		fileId,
+		cuts,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
+
+// serveExport writes the cuts as a plain text file, each under a heading
+// naming where it came from
+func (ch CutsHandle) serveExport(rw http.ResponseWriter, cuts []stat.CutPassage) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Header().Set("Content-Disposition", "attachment; filename=\"cuts.txt\"")
+
+	for _, cut := range cuts {
+		fmt.Fprintf(rw, "== %s, revision %s, %s, %d words ==\n\n%s\n\n", cut.Title, cut.RevId, cut.ModDate, cut.Words, cut.Text)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Settings Handle
+type SettingsHandle struct {
+	db *database.StatTrackerDB
+}
+
+// settingsFromForm reads the settings form over a copy of the current
+// settings, nothing changes unless every field is valid
+func settingsFromForm(req *http.Request) (*stat.Settings, error) {
+	set := *currentSettings()
+
+	mode := req.FormValue("TokenMode")
+	if !stat.IsTokenMode(mode) {
+		return nil, fmt.Errorf("Unknown token mode: %s", mode)
+	}
+	set.TokenMode = mode
+
+	lang := req.FormValue("StopLanguage")
+	if !stat.IsStopLanguage(lang) {
+		return nil, fmt.Errorf("Unknown stop word language: %s", lang)
+	}
+	set.StopLanguage = lang
+	set.StopWords = strings.Fields(strings.ToLower(req.FormValue("StopWords")))
+	set.Stemming = req.FormValue("Stemming") != ""
+
+	phraseRate, errRate := strconv.ParseFloat(req.FormValue("PhraseRate"), 64)
+	if errRate != nil {
+		return nil, fmt.Errorf("Invalid phrase rate: %s", errRate)
+	}
+	set.PhraseRate = phraseRate
+
+	set.FetchSections = req.FormValue("FetchSections") != ""
+
+	timeZone := strings.TrimSpace(req.FormValue("TimeZone"))
+	if _, errZone := time.LoadLocation(timeZone); errZone != nil {
+		return nil, fmt.Errorf("Unknown time zone: %s", errZone)
+	}
+	set.TimeZone = timeZone
+
+	pasteWords, errWords := strconv.Atoi(req.FormValue("PasteWords"))
+	pasteWPM, errWPM := strconv.Atoi(req.FormValue("PasteWPM"))
+	if errWords != nil || errWPM != nil || pasteWords < 0 || pasteWPM < 0 {
+		return nil, fmt.Errorf("Invalid paste limits: %s %s", req.FormValue("PasteWords"), req.FormValue("PasteWPM"))
+	}
+	set.PasteWords = pasteWords
+	set.PasteWPM = pasteWPM
+
+	wordsPerPage, errPage := strconv.Atoi(req.FormValue("WordsPerPage"))
+	readingWPM, errRead := strconv.Atoi(req.FormValue("ReadingWPM"))
+	if errPage != nil || errRead != nil || wordsPerPage <= 0 || readingWPM <= 0 {
+		return nil, fmt.Errorf("Invalid page or reading speed: %v %v", errPage, errRead)
+	}
+	set.WordsPerPage = wordsPerPage
+	set.ReadingWPM = readingWPM
+
+	echoWindow, errEcho := strconv.Atoi(req.FormValue("EchoWindow"))
+	if errEcho != nil || echoWindow < 0 {
+		return nil, fmt.Errorf("Invalid echo window: %s", req.FormValue("EchoWindow"))
+	}
+	set.FillerWords = strings.Fields(strings.ToLower(req.FormValue("FillerWords")))
+	set.EchoWindow = echoWindow
+
+	cutWords, errCut := strconv.Atoi(req.FormValue("CutWords"))
+	if errCut != nil || cutWords < 0 {
+		return nil, fmt.Errorf("Invalid cut size: %s", req.FormValue("CutWords"))
+	}
+	set.CutWords = cutWords
+
+	return &set, nil
+}
+
+func (sh SettingsHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	if req.Method == "POST" {
+		set, err := settingsFromForm(req)
+		if err != nil {
+			http.Error(rw, err.Error(), 400)
+			return
+		}
+		setSettings(set)
+
+		sh.db.WriteSettings(set)
+		if req.FormValue("Recalc") != "" {
+			recalcSet := *set
+			go RecalcStats(sh.db, &recalcSet)
+		}
+		http.Redirect(rw, req, "/settings/", 303)
+		return
+	}
+
+	setTemp, err := template.ParseFiles("./templates/settings.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	set := currentSettings()
+	running, recalcErr := RecalcStatus()
+
+	e := setTemp.Execute(rw, struct {
+		Settings      *stat.Settings
+		TokenModes    []string
+		StopLanguages []string
+		StopWords     string
+		FillerWords   string
+		Recalculating bool
+		RecalcError   error
+	}{
+		set,
+		stat.TokenModes(),
+		stat.StopLanguages(),
+		strings.Join(set.StopWords, " "),
+		strings.Join(set.FillerWords, " "),
+		running,
+		recalcErr,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"GoDriveTracker/database"
+	"GoDriveTracker/stat"
+
+	drive "google.golang.org/api/drive/v2" // DO NOT LIKE THIS! Want to encapse this in google package
+)
+
+var (
+	reProjectPathMatch = regexp.MustCompile("/project/([^/]+)(/[a-z]+)?")
+)
+
+func fileParents(file *drive.File) []string {
+	parents := []string{}
+	for _, p := range file.Parents {
+		parents = append(parents, p.Id)
+	}
+	return parents
+}
+
+// projectDocs loads the stats of every document in a project
+func projectDocs(db *database.StatTrackerDB, project *stat.Project) []*stat.DocStat {
+	docs := []*stat.DocStat{}
+	for f := db.LoadNextFile(""); f != nil; f = db.LoadNextFile(f.Id) {
+		if !project.IsMember(f.Id, fileParents(f)) {
+			continue
+		}
+		if doc := db.LoadFileStats(f.Id); doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// fileProjects maps each file id to the projects it belongs to
+func fileProjects(db *database.StatTrackerDB) map[string][]*stat.Project {
+	result := make(map[string][]*stat.Project)
+	projects := db.LoadProjects()
+	for f := db.LoadNextFile(""); f != nil; f = db.LoadNextFile(f.Id) {
+		parents := fileParents(f)
+		for _, p := range projects {
+			if p.IsMember(f.Id, parents) {
+				result[f.Id] = append(result[f.Id], p)
+			}
+		}
+	}
+	return result
+}
+
+// fileGlossary gathers the glossaries of every project a file belongs to
+func fileGlossary(db *database.StatTrackerDB, fileId string, parents []string) []stat.GlossaryEntry {
+	glossary := []stat.GlossaryEntry{}
+	for _, p := range db.LoadProjects() {
+		if p.IsMember(fileId, parents) {
+			glossary = append(glossary, p.Glossary...)
+		}
+	}
+	return glossary
+}
+
+// recounts are the glossary recounts running in the background, one at a time
+var recounts struct {
+	sync.Mutex
+	sync.WaitGroup
+}
+
+// startRecount runs a recount in the background so the page does not wait on
+// revision text being fetched
+func startRecount(recount func()) {
+	recounts.Add(1)
+	go func() {
+		defer recounts.Done()
+		recounts.Lock()
+		defer recounts.Unlock()
+		recount()
+	}()
+}
+
+// recountEntities counts the glossary again in every document of a project
+func recountEntities(db *database.StatTrackerDB, project *stat.Project) {
+	for f := db.LoadNextFile(""); f != nil; f = db.LoadNextFile(f.Id) {
+		if project.IsMember(f.Id, fileParents(f)) {
+			recountFileEntities(db, f)
+		}
+	}
+}
+
+// recountFileEntities counts the glossaries of a file's projects again in its
+// stored revisions, revision text not stored yet is fetched once
+func recountFileEntities(db *database.StatTrackerDB, file *drive.File) {
+	doc := db.LoadFileStats(file.Id)
+	if doc == nil {
+		return
+	}
+
+	revs := make(map[string]*drive.Revision)
+	for _, r := range db.LoadRevisions(file.Id) {
+		revs[r.Id] = r
+	}
+	stat.RecountEntities(doc, fileGlossary(db, file.Id, fileParents(file)), func(revId string) (string, bool) {
+		r, ok := revs[revId]
+		if !ok {
+			return "", false
+		}
+		text, err := revisionText(db, file.Id, r, "text/plain")
+		if err != nil {
+			log.Println("Recount", file.Id, "revision", revId, err)
+			return "", false
+		}
+		return text, true
+	})
+	db.WriteFileStats(doc)
+}
+
+// entityCharts draws a line chart for each entity that is ever mentioned
+func entityCharts(series []stat.EntitySeries) []entityChart {
+	charts := []entityChart{}
+	for _, es := range series {
+		if es.First == nil {
+			continue
+		}
+		values := []float64{}
+		for _, p := range es.Points {
+			values = append(values, float64(p.Count))
+		}
+		charts = append(charts, entityChart{
+			Series: es,
+			Chart:  makeLineChart(800, 100, svgSeries{Name: es.Entry.Name, Classname: "entityLine", Values: values}),
+		})
+	}
+	return charts
+}
+
+type entityChart struct {
+	Series stat.EntitySeries
+	Chart  *svgLineChart
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Project List Handle
+type ProjectListHandle struct {
+	db *database.StatTrackerDB
+}
+
+type driveFolder struct {
+	Id     string
+	Titles []string
+}
+
+func (ph ProjectListHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	if req.Method == "POST" {
+		name := strings.TrimSpace(req.FormValue("Name"))
+		if name == "" {
+			http.Error(rw, "Project needs a name", 400)
+			return
+		}
+
+		project := &stat.Project{
+			Id:       fmt.Sprintf("%x", time.Now().UnixNano()),
+			Name:     name,
+			FolderId: strings.TrimSpace(req.FormValue("FolderId")),
+			FileIds:  []string{},
+		}
+		ph.db.WriteProject(project)
+
+		http.Redirect(rw, req, "/project/"+project.Id, 303)
+		return
+	}
+
+	// Folders to offer as automatic projects
+	folderMap := make(map[string]*driveFolder)
+	folderIds := []string{}
+	for f := ph.db.LoadNextFile(""); f != nil; f = ph.db.LoadNextFile(f.Id) {
+		for _, p := range f.Parents {
+			if p.IsRoot {
+				continue
+			}
+			folder, ok := folderMap[p.Id]
+			if !ok {
+				folder = &driveFolder{Id: p.Id}
+				folderMap[p.Id] = folder
+				folderIds = append(folderIds, p.Id)
+			}
+			folder.Titles = append(folder.Titles, f.Title)
+		}
+	}
+	sort.Strings(folderIds)
+
+	folders := []*driveFolder{}
+	for _, id := range folderIds {
+		folders = append(folders, folderMap[id])
+	}
+
+	listTemp, err := template.ParseFiles("./templates/projects.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	e := listTemp.Execute(rw, struct {
+		Projects []*stat.Project
+		Folders  []*driveFolder
+	}{
+		ph.db.LoadProjects(),
+		folders,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Project Handle
+type ProjectHandle struct {
+	db *database.StatTrackerDB
+}
+
+func (ph ProjectHandle) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+
+	matches := reProjectPathMatch.FindStringSubmatch(req.URL.Path)
+	if matches == nil {
+		http.Error(rw, "Invalid Path", 400)
+		return
+	}
+
+	project := ph.db.LoadProject(matches[1])
+	if project == nil {
+		fmt.Fprintf(rw, "No project %s", matches[1])
+		return
+	}
+
+	switch matches[2] {
+	case "":
+	case "/members":
+		ph.serveMembers(rw, req, project)
+		return
+	case "/target":
+		ph.serveTarget(rw, req, project)
+		return
+	case "/glossary":
+		ph.serveGlossary(rw, req, project)
+		return
+	default:
+		http.Error(rw, "Invalid Path", 400)
+		return
+	}
+
+	docs := projectDocs(ph.db, project)
+	terms := make(map[string]*stat.DocTerms)
+	for _, d := range docs {
+		terms[d.FileId] = ph.db.LoadDocTerms(d.FileId)
+	}
+	ps := stat.CalcProjectStat(project, docs, terms)
+
+	pointLists := [][]stat.WordPoint{}
+	for _, d := range docs {
+		pointLists = append(pointLists, stat.DocWordPoints(d))
+	}
+	points := stat.SumWordPoints(pointLists)
+
+	var projection *stat.TargetProjection
+	var burnUp *burnUpChart
+	if target := ph.db.LoadTarget(project.TargetId()); target != nil {
+		tp := stat.ProjectTarget(*target, points, time.Now())
+		projection = &tp
+		burnUp = makeBurnUpChart(tp, time.Now())
+	}
+
+	totals := []float64{}
+	for _, p := range points {
+		totals = append(totals, float64(p.Words))
+	}
+
+	// Documents that can be added by hand
+	others := []*stat.DocStat{}
+	members := make(map[string]bool)
+	for _, d := range docs {
+		members[d.FileId] = true
+	}
+	for f := ph.db.LoadNextFileStat(""); f != nil; f = ph.db.LoadNextFileStat(f.FileId) {
+		if !members[f.FileId] {
+			others = append(others, f)
+		}
+	}
+
+	entities := stat.ProjectEntitySeries(docs, project.Glossary)
+
+	projTemp, err := template.ParseFiles("./templates/project.html")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("Error parsing: %s", err), 500)
+		return
+	}
+
+	e := projTemp.Execute(rw, struct {
+		Stat       stat.ProjectStat
+		Projection *stat.TargetProjection
+		BurnUp     *burnUpChart
+		WordChart  *svgLineChart
+		Others     []*stat.DocStat
+		Entities   []stat.EntitySeries
+		Charts     []entityChart
+		Kinds      []string
+	}{
+		ps,
+		projection,
+		burnUp,
+		makeLineChart(800, 200, svgSeries{Name: "Words", Classname: "wordLine", Values: totals}),
+		others,
+		entities,
+		entityCharts(entities),
+		stat.EntityKinds,
+	})
+
+	if e != nil {
+		log.Println("Error in Temp", e)
+	}
+}
+
+// serveMembers adds or removes a document by hand
+func (ph ProjectHandle) serveMembers(rw http.ResponseWriter, req *http.Request, project *stat.Project) {
+	if req.Method != "POST" {
+		http.Error(rw, "Members must be POST", 405)
+		return
+	}
+
+	fileId := req.FormValue("FileId")
+	if req.FormValue("Action") == "remove" {
+		project.RemoveFile(fileId)
+	} else if ph.db.LoadFileStats(fileId) != nil {
+		project.AddFile(fileId)
+	} else {
+		http.Error(rw, fmt.Sprintf("Unknown file: %s", fileId), 400)
+		return
+	}
+
+	ph.db.WriteProject(project)
+	if file := ph.db.LoadFile(fileId); file != nil {
+		startRecount(func() { recountFileEntities(ph.db, file) })
+	}
+	http.Redirect(rw, req, "/project/"+project.Id, 303)
+}
+
+func (ph ProjectHandle) serveTarget(rw http.ResponseWriter, req *http.Request, project *stat.Project) {
+	if req.Method != "POST" {
+		http.Error(rw, "Target must be POST", 405)
+		return
+	}
+
+	target, err := targetFromForm(req, project.TargetId())
+	if err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+
+	ph.db.WriteTarget(target)
+	http.Redirect(rw, req, "/project/"+project.Id, 303)
+}
+
+// serveGlossary adds, replaces or removes a glossary entry
+func (ph ProjectHandle) serveGlossary(rw http.ResponseWriter, req *http.Request, project *stat.Project) {
+	if req.Method != "POST" {
+		http.Error(rw, "Glossary must be POST", 405)
+		return
+	}
+
+	name := strings.TrimSpace(req.FormValue("Name"))
+	if name == "" {
+		http.Error(rw, "Glossary entry needs a name", 400)
+		return
+	}
+
+	if req.FormValue("Action") == "remove" {
+		project.RemoveEntry(name)
+	} else {
+		aliases := []string{}
+		for _, a := range strings.Split(req.FormValue("Aliases"), ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				aliases = append(aliases, a)
+			}
+		}
+
+		project.AddEntry(stat.GlossaryEntry{
+			Name:          name,
+			Kind:          req.FormValue("Kind"),
+			Aliases:       aliases,
+			CaseSensitive: req.FormValue("CaseSensitive") == "on",
+		})
+	}
+
+	ph.db.WriteProject(project)
+	startRecount(func() { recountEntities(ph.db, project) })
+	http.Redirect(rw, req, "/project/"+project.Id, 303)
+}